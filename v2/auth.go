@@ -0,0 +1,228 @@
+package toolkit
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Authenticator adds credentials to an outgoing request. body is the encoded request body,
+// so that it can be signed; it must not be modified
+type Authenticator interface {
+    Authenticate(req *http.Request, body []byte) error
+}
+
+// AuthenticatorFunc lets an ordinary function be used as an Authenticator
+type AuthenticatorFunc func(req *http.Request, body []byte) error
+
+// Authenticate calls f(req, body)
+func (f AuthenticatorFunc) Authenticate(req *http.Request, body []byte) error {
+    return f(req, body)
+}
+
+// BearerToken authenticates requests with a static bearer token
+type BearerToken string
+
+// Authenticate sets the Authorization header
+func (b BearerToken) Authenticate(req *http.Request, _ []byte) error {
+    req.Header.Set("Authorization", "Bearer "+string(b))
+    return nil
+}
+
+// BasicAuth authenticates requests with HTTP basic authentication
+type BasicAuth struct {
+    Username string
+    Password string
+}
+
+// Authenticate sets the Authorization header
+func (b BasicAuth) Authenticate(req *http.Request, _ []byte) error {
+    req.SetBasicAuth(b.Username, b.Password)
+    return nil
+}
+
+// Default headers used by HMACSigner
+const (
+    DefaultSignatureHeader = "X-Signature"
+    DefaultTimestampHeader = "X-Timestamp"
+)
+
+// HMACSigner signs request bodies with HMAC-SHA256. The current unix time is sent in the
+// timestamp header and the signature of "<timestamp>.<body>" in the signature header,
+// formatted as "sha256=<hex digest>"
+type HMACSigner struct {
+    Secret []byte
+    // SignatureHeader is DefaultSignatureHeader if empty
+    SignatureHeader string
+    // TimestampHeader is DefaultTimestampHeader if empty
+    TimestampHeader string
+
+    now func() time.Time
+}
+
+// Authenticate sets the timestamp and signature headers
+func (s *HMACSigner) Authenticate(req *http.Request, body []byte) error {
+    if len(s.Secret) == 0 {
+        return errors.New("hmac signer has no secret")
+    }
+
+    now := time.Now()
+    if s.now != nil {
+        now = s.now()
+    }
+    timestamp := strconv.FormatInt(now.Unix(), 10)
+
+    signatureHeader, timestampHeader := s.SignatureHeader, s.TimestampHeader
+    if signatureHeader == "" {
+        signatureHeader = DefaultSignatureHeader
+    }
+    if timestampHeader == "" {
+        timestampHeader = DefaultTimestampHeader
+    }
+
+    req.Header.Set(timestampHeader, timestamp)
+    req.Header.Set(signatureHeader, "sha256="+signHMAC(s.Secret, timestamp, body))
+    return nil
+}
+
+// signHMAC returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>", or of body alone
+// when timestamp is empty
+func signHMAC(secret []byte, timestamp string, body []byte) string {
+    mac := hmac.New(sha256.New, secret)
+    if timestamp != "" {
+        mac.Write([]byte(timestamp))
+        mac.Write([]byte("."))
+    }
+    mac.Write(body)
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OAuth2ClientCredentials authenticates requests with bearer tokens obtained with the OAuth2
+// client credentials grant (RFC 6749 section 4.4). Tokens are cached and fetched again shortly
+// before they expire. It is safe for concurrent use
+type OAuth2ClientCredentials struct {
+    TokenURL     string
+    ClientID     string
+    ClientSecret string
+    Scopes       []string
+    // HTTPClient is used to fetch tokens, a standard http.Client if nil
+    HTTPClient *http.Client
+
+    mu     sync.Mutex
+    token  string
+    expiry time.Time
+    now    func() time.Time
+}
+
+// oauth2TokenResponse is the successful response of a token endpoint
+type oauth2TokenResponse struct {
+    AccessToken string `json:"access_token"`
+    TokenType   string `json:"token_type"`
+    ExpiresIn   int64  `json:"expires_in"`
+}
+
+// tokenExpiryLeeway is how long before its expiry a cached token is replaced
+const tokenExpiryLeeway = 30 * time.Second
+
+// Authenticate sets the Authorization header, fetching a token first if needed
+func (o *OAuth2ClientCredentials) Authenticate(req *http.Request, _ []byte) error {
+    token, err := o.Token(req.Context())
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Bearer "+token)
+    return nil
+}
+
+// Token returns a valid access token, from the cache when possible
+func (o *OAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    if o.token != "" && (o.expiry.IsZero() || o.clock().Before(o.expiry.Add(-tokenExpiryLeeway))) {
+        return o.token, nil
+    }
+
+    token, expiry, err := o.fetch(ctx)
+    if err != nil {
+        return "", err
+    }
+    o.token, o.expiry = token, expiry
+    return token, nil
+}
+
+// Invalidate drops the cached token, for example after a remote answered 401
+func (o *OAuth2ClientCredentials) Invalidate() {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    o.token, o.expiry = "", time.Time{}
+}
+
+func (o *OAuth2ClientCredentials) clock() time.Time {
+    if o.now != nil {
+        return o.now()
+    }
+    return time.Now()
+}
+
+// fetch requests a new token from the token endpoint
+func (o *OAuth2ClientCredentials) fetch(ctx context.Context) (string, time.Time, error) {
+    form := url.Values{"grant_type": {"client_credentials"}}
+    if len(o.Scopes) > 0 {
+        form.Set("scope", strings.Join(o.Scopes, " "))
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+    req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+    client := o.HTTPClient
+    if client == nil {
+        client = &http.Client{}
+    }
+    response, err := client.Do(req)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    defer response.Body.Close()
+
+    body, err := io.ReadAll(io.LimitReader(response.Body, 1024*1024))
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    if response.StatusCode != http.StatusOK {
+        return "", time.Time{}, fmt.Errorf("token endpoint returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+    }
+
+    var token oauth2TokenResponse
+    if err = json.Unmarshal(body, &token); err != nil {
+        return "", time.Time{}, fmt.Errorf("error decoding token response: %w", err)
+    }
+    if token.AccessToken == "" {
+        return "", time.Time{}, errors.New("token endpoint returned no access token")
+    }
+    if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+        return "", time.Time{}, fmt.Errorf("unsupported token type %q", token.TokenType)
+    }
+
+    var expiry time.Time
+    if token.ExpiresIn > 0 {
+        expiry = o.clock().Add(time.Duration(token.ExpiresIn) * time.Second)
+    }
+    return token.AccessToken, expiry, nil
+}
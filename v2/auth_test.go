@@ -0,0 +1,117 @@
+package toolkit
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestTools_PushJSONToRemoteAuthenticators(t *testing.T) {
+    var received *http.Request
+    client := NewTestClient(func(req *http.Request) *http.Response {
+        received = req
+        return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(http.NoBody)}
+    })
+
+    tests := []struct {
+        name          string
+        authenticator Authenticator
+        header        string
+        expected      string
+    }{
+        {name: "bearer", authenticator: BearerToken("abc"), header: "Authorization", expected: "Bearer abc"},
+        {name: "basic", authenticator: BasicAuth{Username: "joe", Password: "secret"}, header: "Authorization", expected: "Basic am9lOnNlY3JldA=="},
+        {
+            name: "hmac",
+            authenticator: &HMACSigner{
+                Secret: []byte("shh"),
+                now:    func() time.Time { return time.Unix(1700000000, 0) },
+            },
+            header:   DefaultSignatureHeader,
+            expected: "sha256=" + signHMAC([]byte("shh"), "1700000000", []byte(`{"foo":"bar"}`)),
+        },
+    }
+
+    for _, test := range tests {
+        testTools := Tools{Authenticator: test.authenticator}
+        if _, _, err := testTools.PushJSONToRemote("http://example.com/hook", map[string]string{"foo": "bar"}, client); err != nil {
+            t.Fatalf("%s: %s", test.name, err)
+        }
+        if got := received.Header.Get(test.header); got != test.expected {
+            t.Errorf("%s: expected %s %q, got %q", test.name, test.header, test.expected, got)
+        }
+    }
+
+    if received.Header.Get(DefaultTimestampHeader) != "1700000000" {
+        t.Errorf("expected timestamp header, got %q", received.Header.Get(DefaultTimestampHeader))
+    }
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+    var fetches int32
+    tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        user, pass, ok := r.BasicAuth()
+        if !ok || user != "client" || pass != "secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+            w.WriteHeader(http.StatusUnauthorized)
+            return
+        }
+        n := atomic.AddInt32(&fetches, 1)
+        w.Header().Set("Content-Type", "application/json")
+        _, _ = w.Write([]byte(`{"access_token":"token-` + string(rune('0'+n)) + `","token_type":"Bearer","expires_in":3600}`))
+    }))
+    defer tokenServer.Close()
+
+    api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _, _ = w.Write([]byte(`{"auth":"` + r.Header.Get("Authorization") + `"}`))
+    }))
+    defer api.Close()
+
+    now := time.Now()
+    credentials := &OAuth2ClientCredentials{
+        TokenURL:     tokenServer.URL,
+        ClientID:     "client",
+        ClientSecret: "secret",
+        Scopes:       []string{"read", "write"},
+        now:          func() time.Time { return now },
+    }
+
+    var testTools Tools
+    client := testTools.NewJSONClient(api.URL)
+    client.Authenticator = credentials
+
+    var body struct {
+        Auth string `json:"auth"`
+    }
+    for i := 0; i < 2; i++ {
+        if _, err := client.Get(context.Background(), "/", &body); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if body.Auth != "Bearer token-1" || fetches != 1 {
+        t.Errorf("expected cached token-1, got %q after %d fetches", body.Auth, fetches)
+    }
+
+    // the token is refreshed shortly before it expires
+    now = now.Add(3590 * time.Second)
+    if _, err := client.Get(context.Background(), "/", &body); err != nil {
+        t.Fatal(err)
+    }
+    if body.Auth != "Bearer token-2" || fetches != 2 {
+        t.Errorf("expected refreshed token-2, got %q after %d fetches", body.Auth, fetches)
+    }
+
+    credentials.Invalidate()
+    if token, err := credentials.Token(context.Background()); err != nil || token != "token-3" {
+        t.Errorf("expected token-3 after invalidation, got %q %v", token, err)
+    }
+
+    credentials.ClientSecret = "wrong"
+    credentials.Invalidate()
+    if _, err := client.Get(context.Background(), "/", &body); err == nil {
+        t.Error("expected error when the token endpoint refuses the credentials")
+    }
+}
@@ -0,0 +1,214 @@
+package toolkit
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// HTTPError is returned by JSONClient when the remote answers with a status outside 2xx
+type HTTPError struct {
+    StatusCode int
+    Status     string
+    Header     http.Header
+    // Body is the raw response body
+    Body []byte
+    // Problem is the decoded body when it is a JSON object; problem details are decoded into
+    // the standard fields, other members (such as those of a JSONResponse) into Extensions
+    Problem *Problem
+}
+
+// Error describes the status and, when available, the message sent by the remote
+func (e *HTTPError) Error() string {
+    msg := fmt.Sprintf("remote returned %s", e.Status)
+    if e.Problem != nil {
+        if e.Problem.Detail != "" {
+            return msg + ": " + e.Problem.Detail
+        }
+        if m, ok := e.Problem.Extensions["message"].(string); ok && m != "" {
+            return msg + ": " + m
+        }
+    }
+    return msg
+}
+
+// Decode decodes the error body into v
+func (e *HTTPError) Decode(v interface{}) error {
+    return json.Unmarshal(e.Body, v)
+}
+
+// JSONClient sends JSON requests and decodes JSON responses. Requests go through the Retry
+// policy and CircuitBreaker of the Tools which created it, and are compressed according to its Compression
+type JSONClient struct {
+    // BaseURL is prepended to request paths which are not absolute URLs
+    BaseURL string
+    // Headers are sent with every request
+    Headers    http.Header
+    HTTPClient *http.Client
+    // MaxResponseSize limits the response bodies read by the client, after decompression; 10 MB if zero
+    MaxResponseSize int
+    // Authenticator adds credentials to every request; it defaults to the one of the Tools
+    Authenticator Authenticator
+
+    tools *Tools
+}
+
+// NewJSONClient returns a JSON client for baseURL. client is optional - if none is
+// specified, we use standard http.Client
+func (t *Tools) NewJSONClient(baseURL string, client ...*http.Client) *JSONClient {
+    httpClient := &http.Client{}
+    if len(client) > 0 {
+        httpClient = client[0]
+    }
+    return &JSONClient{
+        BaseURL:       baseURL,
+        Headers:       make(http.Header),
+        HTTPClient:    httpClient,
+        Authenticator: t.Authenticator,
+        tools:         t,
+    }
+}
+
+// Get sends a GET request and decodes the response into target
+func (c *JSONClient) Get(ctx context.Context, path string, target interface{}, headers ...http.Header) (*http.Response, error) {
+    return c.Do(ctx, http.MethodGet, path, nil, target, headers...)
+}
+
+// Post sends body as JSON with a POST request and decodes the response into target
+func (c *JSONClient) Post(ctx context.Context, path string, body, target interface{}, headers ...http.Header) (*http.Response, error) {
+    return c.Do(ctx, http.MethodPost, path, body, target, headers...)
+}
+
+// Put sends body as JSON with a PUT request and decodes the response into target
+func (c *JSONClient) Put(ctx context.Context, path string, body, target interface{}, headers ...http.Header) (*http.Response, error) {
+    return c.Do(ctx, http.MethodPut, path, body, target, headers...)
+}
+
+// Patch sends body as JSON with a PATCH request and decodes the response into target
+func (c *JSONClient) Patch(ctx context.Context, path string, body, target interface{}, headers ...http.Header) (*http.Response, error) {
+    return c.Do(ctx, http.MethodPatch, path, body, target, headers...)
+}
+
+// Delete sends a DELETE request and decodes the response into target
+func (c *JSONClient) Delete(ctx context.Context, path string, target interface{}, headers ...http.Header) (*http.Response, error) {
+    return c.Do(ctx, http.MethodDelete, path, nil, target, headers...)
+}
+
+// Do sends a request with body encoded as JSON, unless body is nil, and decodes a successful
+// response into target, unless target is nil or the response has no content.
+// Responses with a status outside 2xx are returned together with an *HTTPError.
+// The returned response body has been read and can be read again by the caller
+func (c *JSONClient) Do(ctx context.Context, method, path string, body, target interface{}, headers ...http.Header) (*http.Response, error) {
+    var (
+        payload []byte
+        err     error
+    )
+    if body != nil {
+        if payload, err = json.Marshal(body); err != nil {
+            return nil, err
+        }
+    }
+    tools := c.tools
+    if tools == nil {
+        tools = &Tools{}
+    }
+    payload, compressed, err := tools.Compression.compress(payload)
+    if err != nil {
+        return nil, err
+    }
+
+    uri := c.url(path)
+    newRequest := func() (*http.Request, error) {
+        var reader io.Reader
+        if payload != nil {
+            reader = bytes.NewReader(payload)
+        }
+        request, err := http.NewRequestWithContext(ctx, method, uri, reader)
+        if err != nil {
+            return nil, err
+        }
+        for k, v := range c.Headers {
+            request.Header[k] = v
+        }
+        if len(headers) > 0 {
+            for k, v := range headers[0] {
+                request.Header[k] = v
+            }
+        }
+        if payload != nil {
+            request.Header.Set("Content-Type", "application/json")
+        }
+        request.Header.Set("Accept", "application/json")
+        tools.Compression.setHeaders(request, compressed, true)
+        if c.Authenticator != nil {
+            if err = c.Authenticator.Authenticate(request, payload); err != nil {
+                return nil, err
+            }
+        }
+        return request, nil
+    }
+
+    httpClient := c.HTTPClient
+    if httpClient == nil {
+        httpClient = &http.Client{}
+    }
+
+    response, err := tools.doWithRetry(ctx, httpClient, newRequest)
+    if err != nil {
+        return nil, err
+    }
+    defer response.Body.Close()
+    if err = decompressResponse(response); err != nil {
+        return response, err
+    }
+
+    maxBytes := c.MaxResponseSize
+    if maxBytes <= 0 {
+        maxBytes = 10 * 1024 * 1024 // 10 MB
+    }
+    data, err := io.ReadAll(io.LimitReader(response.Body, int64(maxBytes)+1))
+    if err != nil {
+        return response, err
+    }
+    if len(data) > maxBytes {
+        return response, fmt.Errorf("response must not be larger than %d bytes", maxBytes)
+    }
+    response.Body = io.NopCloser(bytes.NewReader(data))
+
+    if response.StatusCode < 200 || response.StatusCode > 299 {
+        httpError := &HTTPError{
+            StatusCode: response.StatusCode,
+            Status:     response.Status,
+            Header:     response.Header,
+            Body:       data,
+        }
+        var problem Problem
+        if json.Unmarshal(data, &problem) == nil {
+            httpError.Problem = &problem
+        }
+        return response, httpError
+    }
+
+    if target == nil || len(bytes.TrimSpace(data)) == 0 {
+        return response, nil
+    }
+    if err = json.Unmarshal(data, target); err != nil {
+        return response, fmt.Errorf("error decoding response: %w", err)
+    }
+    return response, nil
+}
+
+// url joins the base URL and path, unless path is already an absolute URL
+func (c *JSONClient) url(path string) string {
+    if c.BaseURL == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+        return path
+    }
+    if path == "" {
+        return c.BaseURL
+    }
+    return strings.TrimRight(c.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
@@ -0,0 +1,211 @@
+package toolkit
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+type clientItem struct {
+    ID   int    `json:"id"`
+    Name string `json:"name"`
+}
+
+func newClientTestServer(t *testing.T) *httptest.Server {
+    return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var testTools Tools
+        if r.Header.Get("Accept") != "application/json" || r.Header.Get("X-Api-Version") != "2" {
+            _ = testTools.ErrorJSON(w, errors.New("missing headers"))
+            return
+        }
+
+        switch {
+        case r.Method == http.MethodGet && r.URL.Path == "/v1/items/1":
+            _ = testTools.WriteJSON(w, http.StatusOK, clientItem{ID: 1, Name: "one"})
+        case r.Method == http.MethodGet && r.URL.Path == "/v1/items/2":
+            testTools.ErrorFormat = ErrorFormatProblem
+            _ = testTools.ErrorJSON(w, NewProblem(http.StatusNotFound, "item 2 does not exist"))
+        case r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch:
+            var item clientItem
+            if err := testTools.ReadJSON(w, r, &item); err != nil {
+                _ = testTools.ErrorJSON(w, err)
+                return
+            }
+            item.ID = 7
+            _ = testTools.WriteJSON(w, http.StatusCreated, item)
+        case r.Method == http.MethodDelete:
+            w.WriteHeader(http.StatusNoContent)
+        case r.URL.Path == "/v1/slow":
+            time.Sleep(200 * time.Millisecond)
+        default:
+            _ = testTools.ErrorJSON(w, errors.New("no route"), http.StatusNotFound)
+        }
+    }))
+}
+
+func TestJSONClient(t *testing.T) {
+    srv := newClientTestServer(t)
+    defer srv.Close()
+
+    var testTools Tools
+    client := testTools.NewJSONClient(srv.URL + "/v1/")
+    client.Headers.Set("X-Api-Version", "2")
+    ctx := context.Background()
+
+    var item clientItem
+    response, err := client.Get(ctx, "items/1", &item)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if item.Name != "one" || response.StatusCode != http.StatusOK {
+        t.Errorf("unexpected item %+v", item)
+    }
+    // the body is still readable by the caller
+    raw, _ := io.ReadAll(response.Body)
+    if len(raw) == 0 {
+        t.Error("expected response body to be readable")
+    }
+
+    for _, do := range []func() (*http.Response, error){
+        func() (*http.Response, error) { return client.Post(ctx, "/items", clientItem{Name: "new"}, &item) },
+        func() (*http.Response, error) { return client.Put(ctx, "/items/7", clientItem{Name: "new"}, &item) },
+        func() (*http.Response, error) { return client.Patch(ctx, "/items/7", clientItem{Name: "new"}, &item) },
+    } {
+        item = clientItem{}
+        response, err = do()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if response.StatusCode != http.StatusCreated || item.ID != 7 || item.Name != "new" {
+            t.Errorf("unexpected response %d %+v", response.StatusCode, item)
+        }
+    }
+
+    response, err = client.Delete(ctx, "/items/7", &item)
+    if err != nil || response.StatusCode != http.StatusNoContent {
+        t.Errorf("unexpected delete result %v", err)
+    }
+}
+
+func TestJSONClient_HTTPError(t *testing.T) {
+    srv := newClientTestServer(t)
+    defer srv.Close()
+
+    var testTools Tools
+    client := testTools.NewJSONClient(srv.URL + "/v1")
+    client.Headers.Set("X-Api-Version", "2")
+
+    _, err := client.Get(context.Background(), "/items/2", nil)
+    var httpError *HTTPError
+    if !errors.As(err, &httpError) {
+        t.Fatalf("expected HTTPError, got %v", err)
+    }
+    if httpError.StatusCode != http.StatusNotFound || httpError.Problem == nil || httpError.Problem.Detail != "item 2 does not exist" {
+        t.Errorf("unexpected error %+v", httpError)
+    }
+
+    _, err = client.Post(context.Background(), "/items", map[string]int{"bogus": 1}, nil)
+    if !errors.As(err, &httpError) || httpError.StatusCode != http.StatusBadRequest {
+        t.Fatalf("expected 400 HTTPError, got %v", err)
+    }
+    var payload JSONResponse
+    if err = httpError.Decode(&payload); err != nil || !payload.Error {
+        t.Errorf("expected JSONResponse body, got %+v %v", payload, err)
+    }
+    if !strings.HasPrefix(httpError.Error(), "remote returned 400 Bad Request: body contains unknown key") {
+        t.Errorf("unexpected error text %q", httpError.Error())
+    }
+}
+
+func TestJSONClient_Context(t *testing.T) {
+    srv := newClientTestServer(t)
+    defer srv.Close()
+
+    var testTools Tools
+    client := testTools.NewJSONClient(srv.URL + "/v1")
+    client.Headers.Set("X-Api-Version", "2")
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    _, err := client.Get(ctx, "/slow", nil)
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("expected deadline exceeded, got %v", err)
+    }
+}
+
+func TestJSONClient_ContextCircuitBreaker(t *testing.T) {
+    srv := newClientTestServer(t)
+    defer srv.Close()
+    host := mustHost(t, srv.URL)
+
+    now := time.Now()
+    breaker := NewCircuitBreaker(2, time.Minute)
+    breaker.now = func() time.Time { return now }
+    testTools := Tools{CircuitBreaker: breaker}
+    client := testTools.NewJSONClient(srv.URL + "/v1")
+    client.Headers.Set("X-Api-Version", "2")
+
+    slow := func(ctx context.Context, cancel context.CancelFunc) error {
+        defer cancel()
+        _, err := client.Get(ctx, "/slow", nil)
+        return err
+    }
+    canceled := func() error {
+        ctx, cancel := context.WithCancel(context.Background())
+        time.AfterFunc(10*time.Millisecond, cancel)
+        return slow(ctx, cancel)
+    }
+    timedOut := func() error {
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+        return slow(ctx, cancel)
+    }
+
+    // cancellations by the caller are not failures of the host
+    for i := 0; i < 2; i++ {
+        if err := canceled(); !errors.Is(err, context.Canceled) {
+            t.Fatalf("expected canceled, got %v", err)
+        }
+    }
+    var item clientItem
+    if _, err := client.Get(context.Background(), "/items/1", &item); err != nil {
+        t.Errorf("expected circuit to stay closed, got %v", err)
+    }
+
+    // a host hanging until the deadline is
+    for i := 0; i < 2; i++ {
+        if err := timedOut(); !errors.Is(err, context.DeadlineExceeded) {
+            t.Fatalf("expected deadline exceeded, got %v", err)
+        }
+    }
+    if _, err := client.Get(context.Background(), "/items/1", &item); !errors.Is(err, ErrCircuitOpen) {
+        t.Errorf("expected circuit to be open, got %v", err)
+    }
+
+    // a canceled probe frees the half-open circuit for the next one
+    now = now.Add(2 * time.Minute)
+    if err := canceled(); !errors.Is(err, context.Canceled) {
+        t.Fatalf("expected canceled, got %v", err)
+    }
+    if err := breaker.Allow(host); err != nil {
+        t.Errorf("expected a new probe to be allowed, got %v", err)
+    }
+}
+
+func TestJSONClient_URL(t *testing.T) {
+    client := JSONClient{BaseURL: "https://api.example.com/v1/"}
+    for path, expected := range map[string]string{
+        "":                       "https://api.example.com/v1/",
+        "users":                  "https://api.example.com/v1/users",
+        "/users?page=2":          "https://api.example.com/v1/users?page=2",
+        "https://other.com/hook": "https://other.com/hook",
+    } {
+        if got := client.url(path); got != expected {
+            t.Errorf("%q: expected %s, got %s", path, expected, got)
+        }
+    }
+}
@@ -0,0 +1,501 @@
+package toolkit
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/url"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// ErrUnsupportedMediaType is returned by ReadBody when no codec is registered for the request Content-Type
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Codec encodes and decodes bodies of one or more media types
+type Codec interface {
+    // MediaTypes returns the media types handled by the codec; the first one is sent in responses
+    MediaTypes() []string
+    Encode(w io.Writer, v interface{}) error
+    Decode(r io.Reader, v interface{}) error
+}
+
+// CodecRegistry is a set of codecs used by ReadBody and WriteBody. The first registered
+// codec is the default, used when a request has no Content-Type or Accept header
+type CodecRegistry struct {
+    mu     sync.RWMutex
+    codecs []Codec
+}
+
+// NewCodecRegistry returns a registry containing codecs, in order of preference
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+    c := &CodecRegistry{}
+    for _, codec := range codecs {
+        c.Register(codec)
+    }
+    return c
+}
+
+// DefaultCodecRegistry returns a registry with JSON (the default), XML, form and MessagePack codecs
+func DefaultCodecRegistry() *CodecRegistry {
+    return NewCodecRegistry(JSONCodec{}, XMLCodec{}, FormCodec{}, MsgPackCodec{})
+}
+
+var defaultCodecs = DefaultCodecRegistry()
+
+// Register adds codec to the registry, replacing any codec handling the same primary media type
+func (c *CodecRegistry) Register(codec Codec) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for i, existing := range c.codecs {
+        if existing.MediaTypes()[0] == codec.MediaTypes()[0] {
+            c.codecs[i] = codec
+            return
+        }
+    }
+    c.codecs = append(c.codecs, codec)
+}
+
+// Lookup returns the codec handling contentType, which may contain parameters such as charset
+func (c *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    if len(c.codecs) == 0 {
+        return nil, false
+    }
+    if strings.TrimSpace(contentType) == "" {
+        return c.codecs[0], true
+    }
+
+    mediaType, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        return nil, false
+    }
+    for _, codec := range c.codecs {
+        for _, mt := range codec.MediaTypes() {
+            if mt == mediaType {
+                return codec, true
+            }
+        }
+    }
+    return nil, false
+}
+
+// Negotiate picks the codec best matching an Accept header, honoring q-values and preferring
+// more specific media ranges. It returns false when every codec is explicitly refused
+func (c *CodecRegistry) Negotiate(accept string) (Codec, bool) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    if len(c.codecs) == 0 {
+        return nil, false
+    }
+    ranges := parseAccept(accept)
+    if len(ranges) == 0 {
+        return c.codecs[0], true
+    }
+
+    var (
+        best  Codec
+        bestQ float64
+    )
+    for _, codec := range c.codecs {
+        for _, mt := range codec.MediaTypes() {
+            if q := acceptQuality(ranges, mt); q > bestQ {
+                best, bestQ = codec, q
+            }
+        }
+    }
+    return best, best != nil
+}
+
+// acceptRange is a single media range from an Accept header
+type acceptRange struct {
+    mediaType string
+    q         float64
+}
+
+func parseAccept(accept string) []acceptRange {
+    var ranges []acceptRange
+    for _, part := range strings.Split(accept, ",") {
+        if strings.TrimSpace(part) == "" {
+            continue
+        }
+        mediaType, params, err := mime.ParseMediaType(part)
+        if err != nil {
+            continue
+        }
+        q := 1.0
+        if v, ok := params["q"]; ok {
+            if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+                continue
+            }
+        }
+        ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+    }
+    return ranges
+}
+
+// acceptQuality returns the q-value of the most specific range matching mediaType
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+    typ, _, _ := strings.Cut(mediaType, "/")
+    q, specificity := 0.0, -1
+    for _, r := range ranges {
+        s := -1
+        switch {
+        case r.mediaType == mediaType:
+            s = 2
+        case r.mediaType == typ+"/*":
+            s = 1
+        case r.mediaType == "*/*":
+            s = 0
+        }
+        if s > specificity {
+            q, specificity = r.q, s
+        }
+    }
+    return q
+}
+
+// codecs returns the registry configured on t, or the default one
+func (t *Tools) codecs() *CodecRegistry {
+    if t.Codecs != nil {
+        return t.Codecs
+    }
+    return defaultCodecs
+}
+
+// ReadBody reads the body of a request with the codec matching its Content-Type and decodes it
+// into data. JSON bodies are handled by ReadJSON; other media types are limited to MaxJSONSize
+func (t *Tools) ReadBody(w http.ResponseWriter, r *http.Request, data interface{}) error {
+    contentType := r.Header.Get("Content-Type")
+    codec, ok := t.codecs().Lookup(contentType)
+    if !ok {
+        return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, contentType)
+    }
+    if _, isJSON := codec.(JSONCodec); isJSON {
+        return t.ReadJSON(w, r, data)
+    }
+
+    maxBytes := t.maxJSONSize()
+    r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+    err := codec.Decode(r.Body, data)
+    if err != nil {
+        var maxBytesError *http.MaxBytesError
+        switch {
+        case errors.As(err, &maxBytesError):
+            return fmt.Errorf("body must not be large than %d bytes", maxBytes)
+        case errors.Is(err, io.EOF):
+            return errors.New("body must not be empty")
+        default:
+            return fmt.Errorf("error decoding %s body: %w", codec.MediaTypes()[0], err)
+        }
+    }
+    if t.ValidateJSON {
+        return t.Validate(data)
+    }
+    return nil
+}
+
+// WriteBody is like WriteJSON, but encodes data with the codec best matching the Accept header
+// of the request. When no codec is acceptable the default codec is used
+func (t *Tools) WriteBody(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+    registry := t.codecs()
+    codec, ok := registry.Negotiate(r.Header.Get("Accept"))
+    if !ok {
+        codec, _ = registry.Lookup("")
+    }
+    if codec == nil {
+        return errors.New("no codecs registered")
+    }
+
+    var out bytes.Buffer
+    err := codec.Encode(&out, data)
+    if err != nil {
+        return err
+    }
+
+    if len(headers) > 0 {
+        for k, v := range headers[0] {
+            w.Header()[k] = v
+        }
+    }
+
+    w.Header().Set("Content-Type", codec.MediaTypes()[0])
+    w.Header().Add("Vary", "Accept")
+    w.WriteHeader(status)
+    _, err = w.Write(out.Bytes())
+    if err != nil {
+        return err
+    }
+    return nil
+}
+
+// JSONCodec encodes and decodes application/json
+type JSONCodec struct{}
+
+// MediaTypes returns the media types handled by JSONCodec
+func (JSONCodec) MediaTypes() []string {
+    return []string{"application/json"}
+}
+
+// Encode writes v as JSON
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+    out, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(out)
+    return err
+}
+
+// Decode reads a JSON value into v
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+    return json.NewDecoder(r).Decode(v)
+}
+
+// XMLCodec encodes and decodes application/xml and text/xml
+type XMLCodec struct{}
+
+// MediaTypes returns the media types handled by XMLCodec
+func (XMLCodec) MediaTypes() []string {
+    return []string{"application/xml", "text/xml"}
+}
+
+// Encode writes v as XML, preceded by the standard XML header
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+    out, err := xml.Marshal(v)
+    if err != nil {
+        return err
+    }
+    _, err = io.WriteString(w, xml.Header)
+    if err != nil {
+        return err
+    }
+    _, err = w.Write(out)
+    return err
+}
+
+// Decode reads an XML document into v
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+    return xml.NewDecoder(r).Decode(v)
+}
+
+// FormCodec encodes and decodes application/x-www-form-urlencoded. Struct fields are matched
+// by their `form` tag, then their `json` tag, then their name. Fields may be strings, booleans,
+// numbers, pointers to those, or slices of those for repeated keys
+type FormCodec struct{}
+
+// MediaTypes returns the media types handled by FormCodec
+func (FormCodec) MediaTypes() []string {
+    return []string{"application/x-www-form-urlencoded"}
+}
+
+// Encode writes v, a struct, map or url.Values, as a url encoded form
+func (FormCodec) Encode(w io.Writer, v interface{}) error {
+    values, err := encodeForm(v)
+    if err != nil {
+        return err
+    }
+    _, err = io.WriteString(w, values.Encode())
+    return err
+}
+
+// Decode reads a url encoded form into v, a pointer to a struct, map or url.Values
+func (FormCodec) Decode(r io.Reader, v interface{}) error {
+    body, err := io.ReadAll(r)
+    if err != nil {
+        return err
+    }
+    if len(body) == 0 {
+        return io.EOF
+    }
+    values, err := url.ParseQuery(string(body))
+    if err != nil {
+        return err
+    }
+    return decodeForm(values, v)
+}
+
+// fieldName returns the external name of a struct field, looking at tags in order,
+// and false if the field is excluded with "-"
+func fieldName(sf reflect.StructField, tags ...string) (string, bool) {
+    for _, tag := range tags {
+        value, ok := sf.Tag.Lookup(tag)
+        if !ok {
+            continue
+        }
+        name, _, _ := strings.Cut(value, ",")
+        if name == "-" {
+            return "", false
+        }
+        if name != "" {
+            return name, true
+        }
+    }
+    return sf.Name, true
+}
+
+func encodeForm(v interface{}) (url.Values, error) {
+    if values, ok := v.(url.Values); ok {
+        return values, nil
+    }
+
+    rv := reflect.Indirect(reflect.ValueOf(v))
+    values := make(url.Values)
+    switch rv.Kind() {
+    case reflect.Map:
+        if rv.Type().Key().Kind() != reflect.String {
+            return nil, fmt.Errorf("form: cannot encode map with %s keys", rv.Type().Key())
+        }
+        iter := rv.MapRange()
+        for iter.Next() {
+            if err := addFormValue(values, iter.Key().String(), iter.Value()); err != nil {
+                return nil, err
+            }
+        }
+    case reflect.Struct:
+        for i := 0; i < rv.NumField(); i++ {
+            sf := rv.Type().Field(i)
+            name, ok := fieldName(sf, "form", "json")
+            if !sf.IsExported() || !ok {
+                continue
+            }
+            if err := addFormValue(values, name, rv.Field(i)); err != nil {
+                return nil, err
+            }
+        }
+    default:
+        return nil, fmt.Errorf("form: cannot encode %s", rv.Kind())
+    }
+    return values, nil
+}
+
+func addFormValue(values url.Values, key string, v reflect.Value) error {
+    for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+        if v.IsNil() {
+            return nil
+        }
+        v = v.Elem()
+    }
+    if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+        for i := 0; i < v.Len(); i++ {
+            if err := addFormValue(values, key, v.Index(i)); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    switch v.Kind() {
+    case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+        values.Add(key, fmt.Sprint(v.Interface()))
+        return nil
+    }
+    return fmt.Errorf("form: cannot encode field %q of kind %s", key, v.Kind())
+}
+
+func decodeForm(values url.Values, v interface{}) error {
+    switch target := v.(type) {
+    case *url.Values:
+        *target = values
+        return nil
+    case *map[string][]string:
+        *target = values
+        return nil
+    case *map[string]string:
+        *target = make(map[string]string, len(values))
+        for k := range values {
+            (*target)[k] = values.Get(k)
+        }
+        return nil
+    }
+
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+        return fmt.Errorf("form: cannot decode into %T", v)
+    }
+    rv = rv.Elem()
+
+    for i := 0; i < rv.NumField(); i++ {
+        sf := rv.Type().Field(i)
+        name, ok := fieldName(sf, "form", "json")
+        if !sf.IsExported() || !ok {
+            continue
+        }
+        raw, present := values[name]
+        if !present {
+            continue
+        }
+        if err := setFormField(rv.Field(i), raw); err != nil {
+            return fmt.Errorf("form: field %q: %w", name, err)
+        }
+    }
+    return nil
+}
+
+func setFormField(v reflect.Value, raw []string) error {
+    switch v.Kind() {
+    case reflect.Pointer:
+        elem := reflect.New(v.Type().Elem())
+        if err := setFormField(elem.Elem(), raw); err != nil {
+            return err
+        }
+        v.Set(elem)
+        return nil
+    case reflect.Slice:
+        slice := reflect.MakeSlice(v.Type(), len(raw), len(raw))
+        for i, s := range raw {
+            if err := setFormScalar(slice.Index(i), s); err != nil {
+                return err
+            }
+        }
+        v.Set(slice)
+        return nil
+    }
+    return setFormScalar(v, raw[0])
+}
+
+func setFormScalar(v reflect.Value, s string) error {
+    switch v.Kind() {
+    case reflect.String:
+        v.SetString(s)
+    case reflect.Bool:
+        b, err := strconv.ParseBool(s)
+        if err != nil {
+            return err
+        }
+        v.SetBool(b)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+        if err != nil {
+            return err
+        }
+        v.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+        if err != nil {
+            return err
+        }
+        v.SetUint(n)
+    case reflect.Float32, reflect.Float64:
+        f, err := strconv.ParseFloat(s, v.Type().Bits())
+        if err != nil {
+            return err
+        }
+        v.SetFloat(f)
+    default:
+        return fmt.Errorf("unsupported kind %s", v.Kind())
+    }
+    return nil
+}
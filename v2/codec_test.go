@@ -0,0 +1,270 @@
+package toolkit
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+type codecPayload struct {
+    XMLName xml.Name `json:"-" xml:"payload"`
+    Name    string   `json:"name" xml:"name"`
+    Count   int      `json:"count" xml:"count"`
+    Tags    []string `json:"tags" xml:"tag"`
+}
+
+var readBodyTests = []struct {
+    name          string
+    contentType   string
+    body          string
+    errorExpected bool
+}{
+    {name: "json", contentType: "application/json", body: `{"name":"foo","count":2,"tags":["a","b"]}`},
+    {name: "no content type", contentType: "", body: `{"name":"foo","count":2,"tags":["a","b"]}`},
+    {name: "xml", contentType: "application/xml; charset=utf-8", body: `<payload><name>foo</name><count>2</count><tag>a</tag><tag>b</tag></payload>`},
+    {name: "form", contentType: "application/x-www-form-urlencoded", body: `name=foo&count=2&tags=a&tags=b`},
+    {name: "bad form value", contentType: "application/x-www-form-urlencoded", body: `name=foo&count=two`, errorExpected: true},
+    {name: "empty form", contentType: "application/x-www-form-urlencoded", body: ``, errorExpected: true},
+    {name: "unsupported", contentType: "text/csv", body: `foo,2`, errorExpected: true},
+}
+
+func TestTools_ReadBody(t *testing.T) {
+    var testTools Tools
+    expected := codecPayload{Name: "foo", Count: 2, Tags: []string{"a", "b"}}
+
+    for _, test := range readBodyTests {
+        req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+        if test.contentType != "" {
+            req.Header.Set("Content-Type", test.contentType)
+        }
+
+        var decoded codecPayload
+        err := testTools.ReadBody(httptest.NewRecorder(), req, &decoded)
+        if test.errorExpected {
+            if err == nil {
+                t.Errorf("%s: error expected, but none received", test.name)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+            continue
+        }
+        decoded.XMLName = xml.Name{}
+        if !reflect.DeepEqual(decoded, expected) {
+            t.Errorf("%s: expected %+v, got %+v", test.name, expected, decoded)
+        }
+    }
+}
+
+func TestTools_ReadBodyUnsupported(t *testing.T) {
+    var testTools Tools
+    req, _ := http.NewRequest("POST", "/", strings.NewReader("a,b"))
+    req.Header.Set("Content-Type", "text/csv")
+
+    var decoded codecPayload
+    err := testTools.ReadBody(httptest.NewRecorder(), req, &decoded)
+    if !errors.Is(err, ErrUnsupportedMediaType) {
+        t.Errorf("expected ErrUnsupportedMediaType, got %v", err)
+    }
+}
+
+var negotiateTests = []struct {
+    name     string
+    accept   string
+    expected string
+}{
+    {name: "no accept", accept: "", expected: "application/json"},
+    {name: "any", accept: "*/*", expected: "application/json"},
+    {name: "xml", accept: "application/xml", expected: "application/xml"},
+    {name: "text xml", accept: "text/xml", expected: "application/xml"},
+    {name: "q values", accept: "application/json;q=0.5, application/msgpack", expected: "application/msgpack"},
+    {name: "specific wins over wildcard", accept: "application/*;q=0.9, application/json;q=0.1", expected: "application/xml"},
+    {name: "refused falls back to default", accept: "image/png", expected: "application/json"},
+}
+
+func TestTools_WriteBody(t *testing.T) {
+    var testTools Tools
+    payload := codecPayload{Name: "foo", Count: 2, Tags: []string{"a", "b"}}
+
+    for _, test := range negotiateTests {
+        req, _ := http.NewRequest("GET", "/", nil)
+        if test.accept != "" {
+            req.Header.Set("Accept", test.accept)
+        }
+        rr := httptest.NewRecorder()
+
+        err := testTools.WriteBody(rr, req, http.StatusOK, payload)
+        if err != nil {
+            t.Errorf("%s: failed to write body: %s", test.name, err.Error())
+            continue
+        }
+        if ct := rr.Header().Get("Content-Type"); ct != test.expected {
+            t.Errorf("%s: expected content type %s, got %s", test.name, test.expected, ct)
+        }
+
+        // the written body must read back through the same codec
+        back, _ := http.NewRequest("POST", "/", bytes.NewReader(rr.Body.Bytes()))
+        back.Header.Set("Content-Type", rr.Header().Get("Content-Type"))
+        var decoded codecPayload
+        if err = testTools.ReadBody(httptest.NewRecorder(), back, &decoded); err != nil {
+            t.Errorf("%s: failed to read body back: %s", test.name, err.Error())
+        }
+        decoded.XMLName = xml.Name{}
+        if !reflect.DeepEqual(decoded, payload) {
+            t.Errorf("%s: expected %+v, got %+v", test.name, payload, decoded)
+        }
+    }
+}
+
+func TestFormCodec_Encode(t *testing.T) {
+    var out bytes.Buffer
+    err := FormCodec{}.Encode(&out, codecPayload{Name: "a b", Count: 1, Tags: []string{"x", "y"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if out.String() != "count=1&name=a+b&tags=x&tags=y" {
+        t.Errorf("wrong form encoding: %s", out.String())
+    }
+}
+
+func TestMsgPackCodec_Encode(t *testing.T) {
+    var out bytes.Buffer
+    err := MsgPackCodec{}.Encode(&out, map[string]interface{}{
+        "a": 1,
+        "b": []interface{}{true, nil, -1, 300, "hi"},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    expected := []byte{
+        0x82,
+        0xa1, 'a', 0x01,
+        0xa1, 'b', 0x95, 0xc3, 0xc0, 0xff, 0xcd, 0x01, 0x2c, 0xa2, 'h', 'i',
+    }
+    if !bytes.Equal(out.Bytes(), expected) {
+        t.Errorf("wrong msgpack encoding: % x", out.Bytes())
+    }
+}
+
+func TestMsgPackCodec_RoundTrip(t *testing.T) {
+    type inner struct {
+        Big   int64   `json:"big"`
+        Neg   int32   `json:"neg"`
+        Float float64 `json:"float"`
+        Data  []byte  `json:"data"`
+    }
+    in := struct {
+        Text  string         `json:"text"`
+        Inner inner          `json:"inner"`
+        List  []inner        `json:"list"`
+        Map   map[string]int `json:"map"`
+        Skip  string         `json:"skip,omitempty"`
+    }{
+        Text:  strings.Repeat("x", 300),
+        Inner: inner{Big: 1 << 40, Neg: -40000, Float: 1.5, Data: []byte{0, 1, 2}},
+        List:  []inner{{Big: -1}},
+        Map:   map[string]int{"a": 1, "b": 70000},
+    }
+
+    var buf bytes.Buffer
+    if err := (MsgPackCodec{}).Encode(&buf, in); err != nil {
+        t.Fatal(err)
+    }
+
+    out := in
+    out.Inner, out.List, out.Map, out.Text = inner{}, nil, nil, ""
+    if err := (MsgPackCodec{}).Decode(&buf, &out); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(in, out) {
+        t.Errorf("round trip mismatch: expected %+v, got %+v", in, out)
+    }
+}
+
+type msgpackBase struct {
+    ID string `msgpack:"i" json:"id"`
+}
+
+type msgpackTagged struct {
+    msgpackBase
+    UserName string            `msgpack:"u" json:"user_name"`
+    Hidden   string            `msgpack:"-" json:"hidden"`
+    Size     Size              `json:"size"`
+    Ptr      *int              `json:"ptr"`
+    Counts   map[int]uint8     `json:"counts"`
+    Fixed    [2]byte           `json:"fixed"`
+    Raw      json.RawMessage   `json:"raw"`
+    Any      interface{}       `json:"any"`
+    Nested   map[string]string `msgpack:"n,omitempty"`
+}
+
+func TestMsgPackCodec_RoundTripTags(t *testing.T) {
+    seven := 7
+    in := msgpackTagged{
+        msgpackBase: msgpackBase{ID: "a1"},
+        UserName:    "joe",
+        Hidden:      "not sent",
+        Size:        2048,
+        Ptr:         &seven,
+        Counts:      map[int]uint8{1: 2},
+        Fixed:       [2]byte{3, 4},
+        Raw:         json.RawMessage(`{"x":[1,2]}`),
+        Any:         "text",
+    }
+
+    var buf bytes.Buffer
+    if err := (MsgPackCodec{}).Encode(&buf, in); err != nil {
+        t.Fatal(err)
+    }
+    var out msgpackTagged
+    if err := (MsgPackCodec{}).Decode(bytes.NewReader(buf.Bytes()), &out); err != nil {
+        t.Fatal(err)
+    }
+
+    in.Hidden = ""
+    in.Raw = nil
+    raw := out.Raw
+    out.Raw = nil
+    if !reflect.DeepEqual(in, out) {
+        t.Errorf("round trip mismatch: expected %+v, got %+v", in, out)
+    }
+    if string(raw) != `{"x":[1,2]}` {
+        t.Errorf("unexpected raw message %s", raw)
+    }
+
+    // keys are matched case-insensitively, and unknown keys are ignored
+    buf.Reset()
+    _ = (MsgPackCodec{}).Encode(&buf, map[string]interface{}{"U": "ann", "other": 1})
+    out = msgpackTagged{}
+    if err := (MsgPackCodec{}).Decode(&buf, &out); err != nil || out.UserName != "ann" {
+        t.Errorf("expected user name ann, got %+v %v", out, err)
+    }
+
+    var small struct {
+        N int8 `json:"n"`
+    }
+    buf.Reset()
+    _ = (MsgPackCodec{}).Encode(&buf, map[string]int{"n": 300})
+    if err := (MsgPackCodec{}).Decode(&buf, &small); err == nil {
+        t.Error("expected an overflow error")
+    }
+    if err := (MsgPackCodec{}).Decode(bytes.NewReader([]byte{0xc0}), small); err == nil {
+        t.Error("expected an error for a non-pointer target")
+    }
+}
+
+func TestMsgPackCodec_DecodeTruncated(t *testing.T) {
+    var out interface{}
+    err := MsgPackCodec{}.Decode(bytes.NewReader([]byte{0x92, 0x01}), &out)
+    if err == nil {
+        t.Error("expected error for truncated input")
+    }
+}
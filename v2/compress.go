@@ -0,0 +1,128 @@
+package toolkit
+
+import (
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// Compression configures gzip compression of the request bodies sent by PushJSONToRemote and
+// JSONClient. Bodies are sent with Content-Encoding: gzip, and JSONClient requests gzip responses.
+// Authenticators sign the compressed body, as it is sent; ReadSignedJSON and VerifySignature
+// verify it as received, before decompressing it
+type Compression struct {
+    // MinSize is the size, in bytes, below which bodies are sent uncompressed; 1 KB if zero
+    MinSize int
+    // Level is the gzip compression level, gzip.DefaultCompression if zero
+    Level int
+}
+
+// compress returns body gzipped, or body unchanged and false when compression is disabled
+// or body is smaller than MinSize
+func (c *Compression) compress(body []byte) ([]byte, bool, error) {
+    if c == nil {
+        return body, false, nil
+    }
+    minSize := c.MinSize
+    if minSize <= 0 {
+        minSize = 1024
+    }
+    if len(body) < minSize {
+        return body, false, nil
+    }
+
+    level := c.Level
+    if level == 0 {
+        level = gzip.DefaultCompression
+    }
+
+    var buf bytes.Buffer
+    zw, err := gzip.NewWriterLevel(&buf, level)
+    if err != nil {
+        return nil, false, err
+    }
+    if _, err = zw.Write(body); err != nil {
+        return nil, false, err
+    }
+    if err = zw.Close(); err != nil {
+        return nil, false, err
+    }
+    return buf.Bytes(), true, nil
+}
+
+// setHeaders marks request as carrying a gzipped body, when compressed, and with decompress
+// asks for a gzipped response, which must then be passed to decompressResponse
+func (c *Compression) setHeaders(request *http.Request, compressed, decompress bool) {
+    if c == nil {
+        return
+    }
+    if compressed {
+        request.Header.Set("Content-Encoding", "gzip")
+    }
+    if decompress {
+        request.Header.Set("Accept-Encoding", "gzip")
+    }
+}
+
+// gzipEncoded reports whether header declares a gzip Content-Encoding
+func gzipEncoded(header http.Header) bool {
+    return strings.EqualFold(strings.TrimSpace(header.Get("Content-Encoding")), "gzip")
+}
+
+// gunzip decompresses a gzip encoded request body, failing once it exceeds maxBytes
+func gunzip(body []byte, maxBytes int) ([]byte, error) {
+    zr, err := gzip.NewReader(bytes.NewReader(body))
+    if err != nil {
+        return nil, fmt.Errorf("body is not valid gzip: %w", err)
+    }
+    defer zr.Close()
+
+    out, err := io.ReadAll(io.LimitReader(zr, int64(maxBytes)+1))
+    if err != nil {
+        return nil, fmt.Errorf("body is not valid gzip: %w", err)
+    }
+    if len(out) > maxBytes {
+        return nil, fmt.Errorf("body must not be large than %d bytes", maxBytes)
+    }
+    return out, nil
+}
+
+// gzipBody decompresses a response body, closing the original body when closed
+type gzipBody struct {
+    *gzip.Reader
+    body io.ReadCloser
+}
+
+func (g *gzipBody) Close() error {
+    _ = g.Reader.Close()
+    return g.body.Close()
+}
+
+// decompressResponse replaces the body of a gzip encoded response with its decompressed
+// content. http.Transport only does so itself for requests without an Accept-Encoding header
+func decompressResponse(response *http.Response) error {
+    if !gzipEncoded(response.Header) {
+        return nil
+    }
+
+    zr, err := gzip.NewReader(response.Body)
+    if errors.Is(err, io.EOF) {
+        // an empty body, as sent with 204 or in answer to HEAD
+        err = nil
+    } else if err == nil {
+        response.Body = &gzipBody{Reader: zr, body: response.Body}
+    }
+    if err != nil {
+        return err
+    }
+
+    response.Header.Del("Content-Encoding")
+    response.Header.Del("Content-Length")
+    response.ContentLength = -1
+    response.Uncompressed = true
+    return nil
+}
@@ -0,0 +1,186 @@
+package toolkit
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+var compressionTests = []struct {
+    name           string
+    compression    *Compression
+    size           int
+    wantCompressed bool
+}{
+    {name: "disabled", compression: nil, size: 4096, wantCompressed: false},
+    {name: "below default threshold", compression: &Compression{}, size: 100, wantCompressed: false},
+    {name: "above default threshold", compression: &Compression{}, size: 4096, wantCompressed: true},
+    {name: "below custom threshold", compression: &Compression{MinSize: 8192}, size: 4096, wantCompressed: false},
+    {name: "best compression", compression: &Compression{Level: gzip.BestCompression}, size: 4096, wantCompressed: true},
+}
+
+// gzipEchoServer decodes gzipped requests and answers with the received payload, gzipped
+// when the client accepts it. It records whether the last request was compressed
+func gzipEchoServer(t *testing.T, compressed *bool, secret []byte) *httptest.Server {
+    return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        raw, _ := io.ReadAll(r.Body)
+        if secret != nil {
+            if err := (&WebhookVerifier{Secrets: [][]byte{secret}}).Verify(r.Header, raw); err != nil {
+                t.Errorf("signature of sent body: %v", err)
+            }
+        }
+
+        body := raw
+        *compressed = r.Header.Get("Content-Encoding") == "gzip"
+        if *compressed {
+            zr, err := gzip.NewReader(bytes.NewReader(raw))
+            if err != nil {
+                t.Fatal(err)
+            }
+            body, _ = io.ReadAll(zr)
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+            _, _ = w.Write(body)
+            return
+        }
+        w.Header().Set("Content-Encoding", "gzip")
+        zw := gzip.NewWriter(w)
+        _, _ = zw.Write(body)
+        _ = zw.Close()
+    }))
+}
+
+func TestTools_PushJSONToRemote_Compression(t *testing.T) {
+    for _, test := range compressionTests {
+        var compressed bool
+        secret := []byte("secret")
+        srv := gzipEchoServer(t, &compressed, secret)
+
+        testTools := Tools{Compression: test.compression, Authenticator: &HMACSigner{Secret: secret}}
+        payload := map[string]string{"data": strings.Repeat("a", test.size)}
+
+        response, status, err := testTools.PushJSONToRemote(srv.URL, payload)
+        srv.Close()
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", test.name, err)
+            continue
+        }
+        if status != http.StatusOK {
+            t.Errorf("%s: expected status 200, got %d", test.name, status)
+        }
+        if compressed != test.wantCompressed {
+            t.Errorf("%s: expected compressed %v, got %v", test.name, test.wantCompressed, compressed)
+        }
+        if response.Header.Get("Content-Encoding") != "" {
+            t.Errorf("%s: Content-Encoding left on decompressed response", test.name)
+        }
+    }
+}
+
+func TestTools_PushJSONToRemote_MalformedGzipResponse(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Encoding", "gzip")
+        w.WriteHeader(http.StatusAccepted)
+        _, _ = w.Write([]byte("not gzip"))
+    }))
+    defer srv.Close()
+
+    // the response body is not read, so a bad encoding must not fail the push
+    testTools := Tools{Compression: &Compression{}}
+    _, status, err := testTools.PushJSONToRemote(srv.URL, map[string]string{"a": "b"})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if status != http.StatusAccepted {
+        t.Errorf("expected status 202, got %d", status)
+    }
+}
+
+func TestTools_ReadSignedJSON_Compressed(t *testing.T) {
+    secret := []byte("secret")
+    verifier := &WebhookVerifier{Secrets: [][]byte{secret}}
+    receiver := Tools{MaxJSONSize: 4096}
+
+    var (
+        received map[string]string
+        readErr  error
+    )
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        received = nil
+        if readErr = receiver.ReadSignedJSON(w, r, verifier, &received); readErr != nil {
+            _ = receiver.ErrorJSON(w, readErr)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    defer srv.Close()
+
+    sender := Tools{Compression: &Compression{MinSize: 1}, Authenticator: &HMACSigner{Secret: secret}}
+    payload := map[string]string{"data": strings.Repeat("a", 2000)}
+    if _, status, err := sender.PushJSONToRemote(srv.URL, payload); err != nil || status != http.StatusNoContent {
+        t.Fatalf("expected the compressed webhook to be accepted, got %d %v %v", status, err, readErr)
+    }
+    if received["data"] != payload["data"] {
+        t.Errorf("payload not decompressed: %v", received)
+    }
+
+    // the decompressed body is held to MaxJSONSize
+    payload["data"] = strings.Repeat("a", 100*1024)
+    if _, status, _ := sender.PushJSONToRemote(srv.URL, payload); status != http.StatusBadRequest {
+        t.Errorf("expected status 400, got %d", status)
+    }
+    if readErr == nil || !strings.Contains(readErr.Error(), "must not be large than 4096 bytes") {
+        t.Errorf("expected a size error, got %v", readErr)
+    }
+}
+
+func TestJSONClient_Compression(t *testing.T) {
+    for _, test := range compressionTests {
+        var compressed bool
+        srv := gzipEchoServer(t, &compressed, nil)
+
+        testTools := Tools{Compression: test.compression}
+        payload := map[string]string{"data": strings.Repeat("a", test.size)}
+
+        var echoed map[string]string
+        _, err := testTools.NewJSONClient(srv.URL).Post(context.Background(), "/", payload, &echoed)
+        srv.Close()
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", test.name, err)
+            continue
+        }
+        if compressed != test.wantCompressed {
+            t.Errorf("%s: expected compressed %v, got %v", test.name, test.wantCompressed, compressed)
+        }
+        if echoed["data"] != payload["data"] {
+            t.Errorf("%s: response was not decompressed", test.name)
+        }
+    }
+}
+
+func TestJSONClient_DecompressedSizeLimit(t *testing.T) {
+    // a small gzip body which expands beyond MaxResponseSize
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Encoding", "gzip")
+        zw := gzip.NewWriter(w)
+        _ = json.NewEncoder(zw).Encode(map[string]string{"data": strings.Repeat("a", 1024*1024)})
+        _ = zw.Close()
+    }))
+    defer srv.Close()
+
+    var testTools Tools
+    client := testTools.NewJSONClient(srv.URL)
+    client.MaxResponseSize = 1024
+
+    if _, err := client.Get(context.Background(), "/", nil); err == nil {
+        t.Error("error expected, but none received")
+    }
+}
@@ -0,0 +1,192 @@
+package toolkit
+
+import (
+    "encoding"
+    "encoding/json"
+    "fmt"
+    "os"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Config holds the settings of Tools which can be loaded from a JSON file, with LoadFile, and
+// from environment variables, with LoadEnv. Every field names its JSON key and its variable,
+// which is prefixed when loaded. Sizes may be human-readable, such as "10MB", lists of file
+// types are comma separated in variables, and booleans accept the values of strconv.ParseBool
+type Config struct {
+    MaxFileSize         Size        `json:"maxFileSize" env:"MAX_FILE_SIZE"`
+    AllowedFileTypes    []string    `json:"allowedFileTypes" env:"ALLOWED_FILE_TYPES"`
+    MaxJSONSize         Size        `json:"maxJSONSize" env:"MAX_JSON_SIZE"`
+    MaxJSONElementSize  Size        `json:"maxJSONElementSize" env:"MAX_JSON_ELEMENT_SIZE"`
+    AllowUnknownFields  bool        `json:"allowUnknownFields" env:"ALLOW_UNKNOWN_FIELDS"`
+    ValidateJSON        bool        `json:"validateJSON" env:"VALIDATE_JSON"`
+    ErrorFormat         ErrorFormat `json:"errorFormat" env:"ERROR_FORMAT"`
+    RedactServerErrors  bool        `json:"redactServerErrors" env:"REDACT_SERVER_ERRORS"`
+    CorrelationIDHeader string      `json:"correlationIDHeader" env:"CORRELATION_ID_HEADER"`
+}
+
+// LoadConfig loads the JSON file at path, unless path is empty, then the environment variables
+// starting with prefix, which take precedence. It fails with a *ConfigError listing every
+// unknown key and invalid value of both
+func LoadConfig(path, prefix string) (Config, error) {
+    var (
+        c        Config
+        problems []string
+    )
+    if path != "" {
+        if err := c.LoadFile(path); err != nil {
+            configError, ok := err.(*ConfigError)
+            if !ok {
+                return c, err
+            }
+            problems = append(problems, configError.Problems...)
+        }
+    }
+    if err := c.LoadEnv(prefix); err != nil {
+        problems = append(problems, err.(*ConfigError).Problems...)
+    }
+
+    if len(problems) > 0 {
+        return c, &ConfigError{Problems: problems}
+    }
+    return c, nil
+}
+
+// LoadFile sets the fields found in the JSON object of the file at path. Other fields are left
+// unchanged. Unknown keys and invalid values are reported together in a *ConfigError
+func (c *Config) LoadFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+
+    var object map[string]json.RawMessage
+    if err = json.Unmarshal(data, &object); err != nil {
+        return fmt.Errorf("%s: %w", path, err)
+    }
+
+    fields := c.fields("json")
+    keys := make([]string, 0, len(object))
+    for key := range object {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    var problems []string
+    for _, key := range keys {
+        field, ok := fields[key]
+        if !ok {
+            problems = append(problems, fmt.Sprintf("%s: unknown key %q", path, key))
+            continue
+        }
+        if err = json.Unmarshal(object[key], field.Addr().Interface()); err != nil {
+            problems = append(problems, fmt.Sprintf("%s: %s: %s", path, key, jsonValueError(err)))
+        }
+    }
+
+    if len(problems) > 0 {
+        return &ConfigError{Problems: problems}
+    }
+    return nil
+}
+
+// jsonValueError describes a decoding error without the Go types of the json package
+func jsonValueError(err error) string {
+    if typeError, ok := err.(*json.UnmarshalTypeError); ok {
+        return fmt.Sprintf("unexpected %s", typeError.Value)
+    }
+    return err.Error()
+}
+
+// LoadEnv sets the fields whose variable, prefixed with prefix, is set. A "_" is added to a
+// prefix which does not end with one, so that "TOOLKIT" reads TOOLKIT_MAX_FILE_SIZE.
+// Variables with the prefix which match no field, and invalid values, are reported together
+// in a *ConfigError
+func (c *Config) LoadEnv(prefix string) error {
+    if prefix != "" && !strings.HasSuffix(prefix, "_") {
+        prefix += "_"
+    }
+
+    fields := c.fields("env")
+    var problems []string
+    for _, variable := range os.Environ() {
+        name, value, _ := strings.Cut(variable, "=")
+        if !strings.HasPrefix(name, prefix) {
+            continue
+        }
+
+        field, ok := fields[strings.TrimPrefix(name, prefix)]
+        if !ok {
+            // without a prefix every variable of the environment would be reported
+            if prefix != "" {
+                problems = append(problems, fmt.Sprintf("%s: unknown variable", name))
+            }
+            continue
+        }
+        if err := setFromString(field, value); err != nil {
+            problems = append(problems, fmt.Sprintf("%s: %s", name, err.Error()))
+        }
+    }
+
+    if len(problems) > 0 {
+        sort.Strings(problems)
+        return &ConfigError{Problems: problems}
+    }
+    return nil
+}
+
+// fields returns the fields of c by the name in their tag
+func (c *Config) fields(tag string) map[string]reflect.Value {
+    v := reflect.ValueOf(c).Elem()
+    fields := make(map[string]reflect.Value, v.NumField())
+    for i := 0; i < v.NumField(); i++ {
+        fields[v.Type().Field(i).Tag.Get(tag)] = v.Field(i)
+    }
+    return fields
+}
+
+// setFromString parses value into field
+func setFromString(field reflect.Value, value string) error {
+    if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+        return unmarshaler.UnmarshalText([]byte(value))
+    }
+
+    switch field.Kind() {
+    case reflect.String:
+        field.SetString(value)
+    case reflect.Bool:
+        b, err := strconv.ParseBool(value)
+        if err != nil {
+            return fmt.Errorf("invalid boolean %q", value)
+        }
+        field.SetBool(b)
+    case reflect.Slice:
+        var items []string
+        for _, item := range strings.Split(value, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                items = append(items, item)
+            }
+        }
+        field.Set(reflect.ValueOf(items))
+    default:
+        return fmt.Errorf("unsupported field type %s", field.Type())
+    }
+    return nil
+}
+
+// WithConfig applies every setting of c. Options given after it take precedence
+func WithConfig(c Config) Option {
+    return func(t *Tools) {
+        t.MaxFileSize = int(c.MaxFileSize)
+        t.AllowedFileTypes = append([]string(nil), c.AllowedFileTypes...)
+        t.MaxJSONSize = int(c.MaxJSONSize)
+        t.MaxJSONElementSize = int(c.MaxJSONElementSize)
+        t.AllowUnknownFields = c.AllowUnknownFields
+        t.ValidateJSON = c.ValidateJSON
+        t.ErrorFormat = c.ErrorFormat
+        t.RedactServerErrors = c.RedactServerErrors
+        t.CorrelationIDHeader = c.CorrelationIDHeader
+    }
+}
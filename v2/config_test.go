@@ -0,0 +1,137 @@
+package toolkit
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+    path := filepath.Join(t.TempDir(), "config.json")
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestConfig_LoadFile(t *testing.T) {
+    path := writeConfigFile(t, `{
+        "maxFileSize": "10MB",
+        "allowedFileTypes": ["image/png", "image/jpeg"],
+        "maxJSONSize": 2048,
+        "allowUnknownFields": true,
+        "errorFormat": "problem"
+    }`)
+
+    var c Config
+    if err := c.LoadFile(path); err != nil {
+        t.Fatal(err)
+    }
+    if c.MaxFileSize != 10_000_000 || len(c.AllowedFileTypes) != 2 || c.MaxJSONSize != 2048 ||
+        !c.AllowUnknownFields || c.ErrorFormat != ErrorFormatProblem {
+        t.Errorf("unexpected config %+v", c)
+    }
+}
+
+func TestConfig_LoadFile_Invalid(t *testing.T) {
+    path := writeConfigFile(t, `{
+        "maxFileSize": "ten megabytes",
+        "allowedFileTypes": "image/png",
+        "maxJsonSize": 2048,
+        "allowUnknownFields": "yes",
+        "errorFormat": "xml"
+    }`)
+
+    var c Config
+    err := c.LoadFile(path)
+    var configError *ConfigError
+    if !errors.As(err, &configError) {
+        t.Fatalf("expected a *ConfigError, got %v", err)
+    }
+    for _, key := range []string{"maxFileSize", "allowedFileTypes", `unknown key "maxJsonSize"`, "allowUnknownFields", "errorFormat"} {
+        if !strings.Contains(err.Error(), key) {
+            t.Errorf("%s not reported in %q", key, err.Error())
+        }
+    }
+    if len(configError.Problems) != 5 {
+        t.Errorf("expected 5 problems, got %v", configError.Problems)
+    }
+
+    if err = c.LoadFile(writeConfigFile(t, `[1, 2]`)); err == nil || errors.As(err, &configError) {
+        t.Errorf("expected a syntax error, got %v", err)
+    }
+    if err = c.LoadFile(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("expected a not exist error, got %v", err)
+    }
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+    t.Setenv("TOOLKIT_MAX_FILE_SIZE", "5MiB")
+    t.Setenv("TOOLKIT_ALLOWED_FILE_TYPES", "image/png, image/gif,")
+    t.Setenv("TOOLKIT_VALIDATE_JSON", "true")
+    t.Setenv("TOOLKIT_CORRELATION_ID_HEADER", "X-Request-ID")
+
+    var c Config
+    if err := c.LoadEnv("TOOLKIT"); err != nil {
+        t.Fatal(err)
+    }
+    if c.MaxFileSize != 5*1024*1024 || strings.Join(c.AllowedFileTypes, ",") != "image/png,image/gif" ||
+        !c.ValidateJSON || c.CorrelationIDHeader != "X-Request-ID" {
+        t.Errorf("unexpected config %+v", c)
+    }
+
+    t.Setenv("TOOLKIT_MAX_JSON_SIZE", "big")
+    t.Setenv("TOOLKIT_ALLOW_UNKNOWN_FIELDS", "maybe")
+    t.Setenv("TOOLKIT_MAX_FILESIZE", "1MB")
+    err := c.LoadEnv("TOOLKIT_")
+    var configError *ConfigError
+    if !errors.As(err, &configError) || len(configError.Problems) != 3 {
+        t.Fatalf("expected 3 problems, got %v", err)
+    }
+    for _, name := range []string{"TOOLKIT_MAX_JSON_SIZE", "TOOLKIT_ALLOW_UNKNOWN_FIELDS", "TOOLKIT_MAX_FILESIZE: unknown variable"} {
+        if !strings.Contains(err.Error(), name) {
+            t.Errorf("%s not reported in %q", name, err.Error())
+        }
+    }
+}
+
+func TestLoadConfig(t *testing.T) {
+    path := writeConfigFile(t, `{"maxFileSize": "10MB", "maxJSONSize": "1KiB", "maxJSONElementSize": "512B", "validateJSON": true}`)
+    t.Setenv("APP_MAX_FILE_SIZE", "20MiB")
+    t.Setenv("APP_VALIDATE_JSON", "false")
+
+    c, err := LoadConfig(path, "APP")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // the environment overrides the file
+    if c.MaxFileSize != 20*1024*1024 || c.MaxJSONSize != 1024 || c.ValidateJSON {
+        t.Errorf("unexpected config %+v", c)
+    }
+
+    tools, err := New(WithConfig(c), WithUnknownJSONFields())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tools.MaxFileSize != 20*1024*1024 || tools.MaxJSONSize != 1024 || !tools.AllowUnknownFields {
+        t.Errorf("config not applied: %+v", tools)
+    }
+    if tools.MaxJSONElementSize != 512 {
+        t.Errorf("expected an element limit of 512, got %d", tools.MaxJSONElementSize)
+    }
+    // options after WithConfig override it, and are validated together
+    if _, err = New(WithConfig(c), WithMaxJSONElementSize(2048)); err == nil {
+        t.Error("error expected, but none received")
+    }
+
+    // problems of the file and the environment are reported together
+    path = writeConfigFile(t, `{"maxFileSize": "huge"}`)
+    t.Setenv("APP_ERROR_FORMAT", "html")
+    _, err = LoadConfig(path, "APP")
+    var configError *ConfigError
+    if !errors.As(err, &configError) || len(configError.Problems) != 2 {
+        t.Errorf("expected 2 problems, got %v", err)
+    }
+}
@@ -0,0 +1,430 @@
+package toolkit
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DeliveryIDHeader is sent with every delivery so that receivers can discard duplicates
+const DeliveryIDHeader = "X-Delivery-ID"
+
+// Delivery is a JSON payload waiting to be delivered to a URL
+type Delivery struct {
+    ID          string          `json:"id"`
+    URL         string          `json:"url"`
+    Payload     json.RawMessage `json:"payload"`
+    Attempts    int             `json:"attempts"`
+    NextAttempt time.Time       `json:"nextAttempt"`
+    LastError   string          `json:"lastError,omitempty"`
+    CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// DeliveryStats is a snapshot of the state of a DeliveryQueue
+type DeliveryStats struct {
+    // Pending is the number of deliveries waiting, including those in flight
+    Pending  int
+    InFlight int
+    // Delivered, Retried and Failed count events since the queue was created
+    Delivered int64
+    Retried   int64
+    Failed    int64
+    // DeadLetters is the number of deliveries stored after running out of attempts
+    DeadLetters int
+}
+
+// DeliveryQueue delivers JSON payloads asynchronously. Every delivery is stored in a file under
+// the queue directory until it succeeds, so that pending deliveries survive restarts.
+// Failed attempts, including responses outside 2xx, are retried with exponential backoff;
+// after MaxAttempts the delivery is moved to dead-letter storage, from where it can be requeued.
+// Deliveries refused by the open CircuitBreaker of the Tools are not counted as attempts, and
+// are postponed by its OpenTimeout.
+// Requests are sent with a JSONClient of the Tools which created the queue
+type DeliveryQueue struct {
+    // Workers is the number of concurrent deliveries, 4 if zero
+    Workers int
+    // MaxAttempts is the number of attempts before a delivery is dead-lettered, 20 if zero.
+    // With the default backoff, 20 attempts span about 11 hours
+    MaxAttempts int
+    // Backoff configures the wait between attempts; only its backoff settings are used,
+    // with InitialBackoff 10s and MaxBackoff 1h if zero
+    Backoff RetryPolicy
+    // Timeout limits every attempt, 30s if zero
+    Timeout time.Duration
+    // HTTPClient is used to send deliveries, a standard http.Client if nil
+    HTTPClient *http.Client
+
+    tools    *Tools
+    dir      string
+    wake     chan struct{}
+    mu       sync.Mutex
+    pending  map[string]*Delivery
+    inFlight map[string]bool
+    dead     int
+    stats    DeliveryStats
+}
+
+// NewDeliveryQueue returns a queue storing its deliveries under dir, loading the deliveries
+// left pending by a previous run
+func (t *Tools) NewDeliveryQueue(dir string) (*DeliveryQueue, error) {
+    q := &DeliveryQueue{
+        tools:    t,
+        dir:      dir,
+        wake:     make(chan struct{}, 1),
+        pending:  make(map[string]*Delivery),
+        inFlight: make(map[string]bool),
+    }
+
+    for _, sub := range []string{q.pendingDir(), q.deadDir()} {
+        if err := t.CreateDirIfNotExist(sub); err != nil {
+            return nil, err
+        }
+    }
+
+    pending, err := readDeliveries(q.pendingDir())
+    if err != nil {
+        return nil, err
+    }
+    for _, d := range pending {
+        q.pending[d.ID] = d
+    }
+
+    dead, err := readDeliveries(q.deadDir())
+    if err != nil {
+        return nil, err
+    }
+    q.dead = len(dead)
+    return q, nil
+}
+
+func (q *DeliveryQueue) pendingDir() string {
+    return filepath.Join(q.dir, "pending")
+}
+
+func (q *DeliveryQueue) deadDir() string {
+    return filepath.Join(q.dir, "dead")
+}
+
+// Enqueue stores payload, encoded as JSON, for delivery to url and returns the delivery ID.
+// The delivery is persisted before Enqueue returns
+func (q *DeliveryQueue) Enqueue(url string, payload interface{}) (string, error) {
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return "", err
+    }
+
+    now := time.Now().UTC()
+    d := &Delivery{
+        ID:          fmt.Sprintf("%d-%s", now.UnixNano(), q.tools.RandomString(8)),
+        URL:         url,
+        Payload:     data,
+        NextAttempt: now,
+        CreatedAt:   now,
+    }
+
+    q.mu.Lock()
+    err = writeDelivery(q.pendingDir(), d)
+    if err == nil {
+        q.pending[d.ID] = d
+    }
+    q.mu.Unlock()
+    if err != nil {
+        return "", err
+    }
+
+    q.notify()
+    return d.ID, nil
+}
+
+// notify wakes the dispatcher up without blocking
+func (q *DeliveryQueue) notify() {
+    select {
+    case q.wake <- struct{}{}:
+    default:
+    }
+}
+
+// Run delivers pending payloads until ctx is done, then waits for attempts in flight to stop.
+// Attempts interrupted by ctx are not counted and will be made again by the next run
+func (q *DeliveryQueue) Run(ctx context.Context) error {
+    workers := q.Workers
+    if workers <= 0 {
+        workers = 4
+    }
+
+    jobs := make(chan *Delivery)
+    var wg sync.WaitGroup
+    for i := 0; i < workers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for d := range jobs {
+                q.attempt(ctx, d)
+            }
+        }()
+    }
+    defer func() {
+        close(jobs)
+        wg.Wait()
+    }()
+
+    for {
+        d, wait := q.nextDue()
+        if d != nil {
+            select {
+            case jobs <- d:
+                continue
+            case <-ctx.Done():
+                q.release(d)
+                return ctx.Err()
+            }
+        }
+
+        // wake up at least once a minute, in case the clock jumped
+        if wait <= 0 || wait > time.Minute {
+            wait = time.Minute
+        }
+        timer := time.NewTimer(wait)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        case <-q.wake:
+            timer.Stop()
+        case <-timer.C:
+        }
+    }
+}
+
+// nextDue claims the oldest delivery which is due, or returns how long until the next one is
+func (q *DeliveryQueue) nextDue() (*Delivery, time.Duration) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    now := time.Now()
+    var (
+        due  *Delivery
+        next time.Time
+    )
+    for _, d := range q.pending {
+        if q.inFlight[d.ID] {
+            continue
+        }
+        if !d.NextAttempt.After(now) {
+            if due == nil || d.NextAttempt.Before(due.NextAttempt) {
+                due = d
+            }
+        } else if next.IsZero() || d.NextAttempt.Before(next) {
+            next = d.NextAttempt
+        }
+    }
+
+    if due != nil {
+        q.inFlight[due.ID] = true
+        return due, 0
+    }
+    if next.IsZero() {
+        return nil, 0
+    }
+    return nil, next.Sub(now)
+}
+
+func (q *DeliveryQueue) release(d *Delivery) {
+    // the dispatcher does not wait for deliveries in flight, so wake it up to reschedule
+    defer q.notify()
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    delete(q.inFlight, d.ID)
+}
+
+// attempt sends d once and records the outcome
+func (q *DeliveryQueue) attempt(ctx context.Context, d *Delivery) {
+    timeout := q.Timeout
+    if timeout <= 0 {
+        timeout = 30 * time.Second
+    }
+    attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    // the queue schedules its own retries, so the Retry policy of the Tools is left out
+    tools := &Tools{
+        Authenticator:  q.tools.Authenticator,
+        CircuitBreaker: q.tools.CircuitBreaker,
+        Compression:    q.tools.Compression,
+    }
+    client := tools.NewJSONClient("")
+    if q.HTTPClient != nil {
+        client.HTTPClient = q.HTTPClient
+    }
+    header := make(http.Header)
+    header.Set(DeliveryIDHeader, d.ID)
+    _, err := client.Post(attemptCtx, d.URL, d.Payload, nil, header)
+
+    if ctx.Err() != nil {
+        q.release(d)
+        return
+    }
+
+    // the dispatcher does not wait for deliveries in flight, so wake it up to reschedule
+    defer q.notify()
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    delete(q.inFlight, d.ID)
+
+    if err == nil {
+        delete(q.pending, d.ID)
+        _ = os.Remove(deliveryPath(q.pendingDir(), d.ID))
+        q.stats.Delivered++
+        return
+    }
+
+    d.LastError = err.Error()
+    if errors.Is(err, ErrCircuitOpen) {
+        // nothing was sent, so wait for the circuit to let requests through again
+        d.NextAttempt = time.Now().UTC().Add(tools.CircuitBreaker.openTimeout())
+        if werr := writeDelivery(q.pendingDir(), d); werr != nil {
+            q.tools.logger().Error("cannot store delivery", "id", d.ID, "error", werr.Error())
+        }
+        return
+    }
+    d.Attempts++
+
+    maxAttempts := q.MaxAttempts
+    if maxAttempts <= 0 {
+        maxAttempts = 20
+    }
+    if d.Attempts >= maxAttempts {
+        if werr := writeDelivery(q.deadDir(), d); werr != nil {
+            q.tools.logger().Error("cannot store dead letter", "id", d.ID, "error", werr.Error())
+        } else {
+            delete(q.pending, d.ID)
+            _ = os.Remove(deliveryPath(q.pendingDir(), d.ID))
+            q.dead++
+        }
+        q.stats.Failed++
+        return
+    }
+
+    d.NextAttempt = time.Now().UTC().Add(q.backoff(d.Attempts))
+    if werr := writeDelivery(q.pendingDir(), d); werr != nil {
+        q.tools.logger().Error("cannot store delivery", "id", d.ID, "error", werr.Error())
+    }
+    q.stats.Retried++
+}
+
+// backoff returns the wait before retry number n. The defaults are longer than those of
+// RetryPolicy, so that deliveries outlast outages of a few hours
+func (q *DeliveryQueue) backoff(n int) time.Duration {
+    policy := q.Backoff
+    if policy.InitialBackoff <= 0 {
+        policy.InitialBackoff = 10 * time.Second
+    }
+    if policy.MaxBackoff <= 0 {
+        policy.MaxBackoff = time.Hour
+    }
+    return policy.backoff(n)
+}
+
+// Stats returns a snapshot of the queue state and counters
+func (q *DeliveryQueue) Stats() DeliveryStats {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    stats := q.stats
+    stats.Pending = len(q.pending)
+    stats.InFlight = len(q.inFlight)
+    stats.DeadLetters = q.dead
+    return stats
+}
+
+// DeadLetters returns the deliveries which ran out of attempts, oldest first
+func (q *DeliveryQueue) DeadLetters() ([]*Delivery, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    return readDeliveries(q.deadDir())
+}
+
+// Requeue moves a dead-lettered delivery back to the queue with a fresh set of attempts
+func (q *DeliveryQueue) Requeue(id string) error {
+    if id == "" || id != filepath.Base(id) {
+        return fmt.Errorf("invalid delivery id %q", id)
+    }
+
+    q.mu.Lock()
+    path := deliveryPath(q.deadDir(), id)
+    d, err := readDelivery(path)
+    if err == nil {
+        d.Attempts, d.LastError, d.NextAttempt = 0, "", time.Now().UTC()
+        err = writeDelivery(q.pendingDir(), d)
+    }
+    if err == nil {
+        q.pending[d.ID] = d
+        q.dead--
+        err = os.Remove(path)
+    }
+    q.mu.Unlock()
+    if err != nil {
+        return err
+    }
+
+    q.notify()
+    return nil
+}
+
+func deliveryPath(dir, id string) string {
+    return filepath.Join(dir, id+".json")
+}
+
+// writeDelivery stores d in dir, replacing any previous version atomically
+func writeDelivery(dir string, d *Delivery) error {
+    data, err := json.Marshal(d)
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(deliveryPath(dir, d.ID), data, 0644)
+}
+
+func readDelivery(path string) (*Delivery, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var d Delivery
+    if err = json.Unmarshal(data, &d); err != nil {
+        return nil, fmt.Errorf("corrupt delivery %s: %w", path, err)
+    }
+    return &d, nil
+}
+
+// readDeliveries reads every delivery stored in dir, oldest first
+func readDeliveries(dir string) ([]*Delivery, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    var deliveries []*Delivery
+    for _, entry := range entries {
+        if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+            continue
+        }
+        d, err := readDelivery(filepath.Join(dir, entry.Name()))
+        if err != nil {
+            return nil, err
+        }
+        deliveries = append(deliveries, d)
+    }
+
+    sort.Slice(deliveries, func(i, j int) bool {
+        return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+    })
+    return deliveries, nil
+}
@@ -0,0 +1,205 @@
+package toolkit
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// waitFor polls cond until it is true or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for !cond() {
+        if time.Now().After(deadline) {
+            t.Fatalf("timed out waiting for %s", what)
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+}
+
+func TestDeliveryQueue(t *testing.T) {
+    var (
+        calls    int32
+        mu       sync.Mutex
+        received []string
+        ids      = make(map[string]bool)
+    )
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // every delivery fails once before it succeeds
+        mu.Lock()
+        defer mu.Unlock()
+        atomic.AddInt32(&calls, 1)
+        id := r.Header.Get(DeliveryIDHeader)
+        if !ids[id] {
+            ids[id] = true
+            w.WriteHeader(http.StatusServiceUnavailable)
+            return
+        }
+        var payload map[string]string
+        _ = json.NewDecoder(r.Body).Decode(&payload)
+        received = append(received, payload["event"])
+    }))
+    defer srv.Close()
+
+    dir := t.TempDir()
+    var testTools Tools
+
+    // deliveries enqueued before a restart are picked up by the next queue
+    q, err := testTools.NewDeliveryQueue(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err = q.Enqueue(srv.URL, map[string]string{"event": "first"}); err != nil {
+        t.Fatal(err)
+    }
+
+    q, err = testTools.NewDeliveryQueue(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if q.Stats().Pending != 1 {
+        t.Fatalf("expected 1 pending delivery after restart, got %+v", q.Stats())
+    }
+    q.Backoff = RetryPolicy{InitialBackoff: time.Millisecond}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error)
+    go func() { done <- q.Run(ctx) }()
+
+    if _, err = q.Enqueue(srv.URL, map[string]string{"event": "second"}); err != nil {
+        t.Fatal(err)
+    }
+    waitFor(t, "deliveries", func() bool { return q.Stats().Delivered == 2 })
+
+    cancel()
+    <-done
+
+    stats := q.Stats()
+    if stats.Pending != 0 || stats.Retried != 2 || stats.Failed != 0 || calls != 4 {
+        t.Errorf("unexpected stats %+v after %d calls", stats, calls)
+    }
+    mu.Lock()
+    if len(received) != 2 {
+        t.Errorf("expected 2 payloads, got %v", received)
+    }
+    mu.Unlock()
+
+    pending, _ := readDeliveries(q.pendingDir())
+    if len(pending) != 0 {
+        t.Errorf("expected no pending files, got %d", len(pending))
+    }
+}
+
+func TestDeliveryQueue_CircuitOpen(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusInternalServerError)
+    }))
+    defer srv.Close()
+
+    testTools := Tools{CircuitBreaker: NewCircuitBreaker(1, time.Hour)}
+    q, err := testTools.NewDeliveryQueue(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    q.MaxAttempts = 2
+    q.Workers = 1
+    q.Backoff = RetryPolicy{InitialBackoff: time.Millisecond}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() { _ = q.Run(ctx) }()
+
+    id, err := q.Enqueue(srv.URL, "payload")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // the first failure opens the circuit, which then refuses the second attempt
+    var d Delivery
+    waitFor(t, "postponed delivery", func() bool {
+        q.mu.Lock()
+        defer q.mu.Unlock()
+        if p, ok := q.pending[id]; ok && !q.inFlight[id] {
+            d = *p
+        }
+        return strings.Contains(d.LastError, "circuit breaker is open")
+    })
+    if d.Attempts != 1 || time.Until(d.NextAttempt) < 59*time.Minute {
+        t.Errorf("expected 1 attempt and a delivery postponed by an hour, got %+v", d)
+    }
+    if stats := q.Stats(); stats.DeadLetters != 0 || stats.Failed != 0 || stats.Pending != 1 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+    stored, err := readDelivery(deliveryPath(q.pendingDir(), id))
+    if err != nil || !stored.NextAttempt.Equal(d.NextAttempt) {
+        t.Errorf("postponed delivery not stored: %+v %v", stored, err)
+    }
+}
+
+func TestDeliveryQueue_DefaultBackoff(t *testing.T) {
+    var q DeliveryQueue
+    var total time.Duration
+    for n := 1; n < 20; n++ {
+        total += q.backoff(n)
+    }
+    if total < 10*time.Hour {
+        t.Errorf("expected the default attempts to span hours, got %s", total)
+    }
+}
+
+func TestDeliveryQueue_DeadLetters(t *testing.T) {
+    var healthy int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if atomic.LoadInt32(&healthy) == 0 {
+            w.WriteHeader(http.StatusInternalServerError)
+        }
+    }))
+    defer srv.Close()
+
+    var testTools Tools
+    q, err := testTools.NewDeliveryQueue(t.TempDir())
+    if err != nil {
+        t.Fatal(err)
+    }
+    q.MaxAttempts = 2
+    q.Workers = 1
+    q.Backoff = RetryPolicy{InitialBackoff: time.Millisecond}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() { _ = q.Run(ctx) }()
+
+    id, err := q.Enqueue(srv.URL, "payload")
+    if err != nil {
+        t.Fatal(err)
+    }
+    waitFor(t, "dead letter", func() bool { return q.Stats().DeadLetters == 1 })
+
+    dead, err := q.DeadLetters()
+    if err != nil || len(dead) != 1 || dead[0].ID != id || dead[0].Attempts != 2 || dead[0].LastError == "" {
+        t.Fatalf("unexpected dead letters %+v %v", dead, err)
+    }
+    if stats := q.Stats(); stats.Failed != 1 || stats.Pending != 0 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+
+    atomic.StoreInt32(&healthy, 1)
+    if err = q.Requeue(id); err != nil {
+        t.Fatal(err)
+    }
+    waitFor(t, "requeued delivery", func() bool { return q.Stats().Delivered == 1 })
+    if stats := q.Stats(); stats.DeadLetters != 0 {
+        t.Errorf("unexpected stats %+v", stats)
+    }
+
+    if err = q.Requeue("../escape"); err == nil {
+        t.Error("expected invalid id to be refused")
+    }
+}
@@ -0,0 +1,153 @@
+package toolkit
+
+import (
+    "errors"
+    "net/http"
+    "reflect"
+    "sync"
+)
+
+// ErrorMapping describes how ErrorJSON presents a class of errors to clients
+type ErrorMapping struct {
+    Status int
+    // Message replaces the error text sent to clients when not empty
+    Message string
+}
+
+// errorMatcher pairs a matching function with the mapping it selects
+type errorMatcher struct {
+    match   func(error) bool
+    mapping ErrorMapping
+}
+
+// ErrorRegistry maps errors to HTTP status codes and public messages. ErrorJSON consults it
+// when called without a status; the first registered match wins. Errors that match nothing
+// are sent with DefaultStatus, and the text of errors sent with a 5xx status is replaced by
+// the status text unless the mapping provides a message
+type ErrorRegistry struct {
+    // DefaultStatus is used for errors that match nothing, 500 if zero
+    DefaultStatus int
+
+    mu       sync.RWMutex
+    matchers []errorMatcher
+}
+
+// NewErrorRegistry returns an empty registry
+func NewErrorRegistry() *ErrorRegistry {
+    return &ErrorRegistry{}
+}
+
+// Register maps errors matching target with errors.Is to status, and optionally to a public message
+func (r *ErrorRegistry) Register(target error, status int, message ...string) {
+    r.RegisterFunc(func(err error) bool {
+        return errors.Is(err, target)
+    }, status, message...)
+}
+
+// RegisterType maps errors of the type of target, found with errors.As, to status and optionally
+// to a public message. Target is a value of the error type, for example (*os.PathError)(nil)
+func (r *ErrorRegistry) RegisterType(target error, status int, message ...string) {
+    typ := reflect.TypeOf(target)
+    r.RegisterFunc(func(err error) bool {
+        return errors.As(err, reflect.New(typ).Interface())
+    }, status, message...)
+}
+
+// RegisterFunc maps errors for which match returns true to status, and optionally to a public message
+func (r *ErrorRegistry) RegisterFunc(match func(error) bool, status int, message ...string) {
+    mapping := ErrorMapping{Status: status}
+    if len(message) > 0 {
+        mapping.Message = message[0]
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.matchers = append(r.matchers, errorMatcher{match: match, mapping: mapping})
+}
+
+// Lookup returns the mapping of the first registered matcher accepting err
+func (r *ErrorRegistry) Lookup(err error) (ErrorMapping, bool) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    for _, m := range r.matchers {
+        if m.match(err) {
+            return m.mapping, true
+        }
+    }
+    return ErrorMapping{}, false
+}
+
+// errorResponse works out the status and the client facing error for err. Explicit statuses win,
+// then the status of a *Problem, then the Errors registry, then 422 for ValidationErrors.
+// It reports true when err has been replaced because its text must not reach clients
+func (t *Tools) errorResponse(err error, status []int) (statusCode int, public error, hidden bool) {
+    if len(status) > 0 {
+        return status[0], err, false
+    }
+
+    var (
+        problem          *Problem
+        validationErrors ValidationErrors
+    )
+    if errors.As(err, &problem) && problem.Status != 0 {
+        return problem.Status, err, false
+    }
+
+    if t.Errors != nil {
+        mapping, ok := t.Errors.Lookup(err)
+        if !ok && !errors.As(err, &validationErrors) {
+            mapping.Status = t.Errors.DefaultStatus
+            if mapping.Status == 0 {
+                mapping.Status = http.StatusInternalServerError
+            }
+        }
+        if mapping.Status != 0 {
+            if mapping.Message == "" && mapping.Status >= http.StatusInternalServerError {
+                mapping.Message = http.StatusText(mapping.Status)
+            }
+            if mapping.Message == "" {
+                return mapping.Status, err, false
+            }
+            return mapping.Status, &Problem{Status: mapping.Status, Detail: mapping.Message, Err: err}, true
+        }
+    }
+
+    if errors.As(err, &validationErrors) {
+        return http.StatusUnprocessableEntity, err, false
+    }
+    return http.StatusBadRequest, err, false
+}
+
+// DefaultCorrelationIDHeader is the response header carrying correlation IDs when
+// CorrelationIDHeader is not set
+const DefaultCorrelationIDHeader = "X-Correlation-ID"
+
+// redactError replaces a server error with a generic message and a correlation ID, which is
+// also sent in a response header and logged together with the original error. A correlation ID
+// already present in the response headers, for example set by a middleware, is reused.
+// Details of a *Problem are considered public and are kept
+func (t *Tools) redactError(w http.ResponseWriter, err, public error, status int) error {
+    header := t.CorrelationIDHeader
+    if header == "" {
+        header = DefaultCorrelationIDHeader
+    }
+    id := w.Header().Get(header)
+    if id == "" {
+        id = t.RandomString(16)
+        w.Header().Set(header, id)
+    }
+
+    t.logger().Error("server error", "status", status, "correlation_id", id, "error", err.Error())
+
+    problem := &Problem{Status: status, Detail: http.StatusText(status), Err: err}
+    var p *Problem
+    if errors.As(public, &p) {
+        problem = &Problem{}
+        *problem = *p
+    }
+    problem.Extensions = copyExtensions(problem.Extensions)
+    problem.Extensions["correlationId"] = id
+    return problem
+}
+
@@ -0,0 +1,162 @@
+package toolkit
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "strings"
+    "testing"
+)
+
+var errNotFound = errors.New("record not found")
+
+var errorRegistryTests = []struct {
+    name            string
+    err             error
+    status          []int
+    expectedStatus  int
+    expectedMessage string
+    expectLog       bool
+}{
+    {name: "sentinel", err: fmt.Errorf("loading user: %w", errNotFound), expectedStatus: http.StatusNotFound, expectedMessage: "loading user: record not found"},
+    {name: "sentinel with message", err: os.ErrPermission, expectedStatus: http.StatusForbidden, expectedMessage: "access denied", expectLog: true},
+    {name: "type", err: &fs.PathError{Op: "open", Path: "/etc/secret", Err: fs.ErrNotExist}, expectedStatus: http.StatusInternalServerError, expectedMessage: "Internal Server Error", expectLog: true},
+    {name: "unmapped", err: errors.New("pq: connection refused"), expectedStatus: http.StatusInternalServerError, expectedMessage: "Internal Server Error", expectLog: true},
+    {name: "explicit status wins", err: errNotFound, status: []int{http.StatusGone}, expectedStatus: http.StatusGone, expectedMessage: "record not found"},
+    {name: "problem wins", err: NewProblem(http.StatusConflict, "duplicate"), expectedStatus: http.StatusConflict, expectedMessage: "duplicate"},
+    {name: "validation", err: ValidationErrors{"name": {"is required"}}, expectedStatus: http.StatusUnprocessableEntity, expectedMessage: "validation failed"},
+}
+
+func TestTools_ErrorJSONRegistry(t *testing.T) {
+    registry := NewErrorRegistry()
+    registry.Register(errNotFound, http.StatusNotFound)
+    registry.Register(os.ErrPermission, http.StatusForbidden, "access denied")
+    registry.RegisterType((*fs.PathError)(nil), http.StatusInternalServerError)
+
+    for _, test := range errorRegistryTests {
+        var logs bytes.Buffer
+        testTools := Tools{
+            Errors: registry,
+            Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+        }
+
+        rr := httptest.NewRecorder()
+        if err := testTools.ErrorJSON(rr, test.err, test.status...); err != nil {
+            t.Fatal(err)
+        }
+
+        var payload JSONResponse
+        if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+            t.Fatal("received error when decoding JSON:", err)
+        }
+        if rr.Code != test.expectedStatus {
+            t.Errorf("%s: expected status %d, got %d", test.name, test.expectedStatus, rr.Code)
+        }
+        if payload.Message != test.expectedMessage {
+            t.Errorf("%s: expected message %q, got %q", test.name, test.expectedMessage, payload.Message)
+        }
+        if test.expectLog != strings.Contains(logs.String(), test.err.Error()) {
+            t.Errorf("%s: expected logged %v, got log %q", test.name, test.expectLog, logs.String())
+        }
+    }
+}
+
+func TestErrorRegistry_Lookup(t *testing.T) {
+    registry := NewErrorRegistry()
+    registry.RegisterFunc(func(err error) bool {
+        return strings.HasPrefix(err.Error(), "timeout")
+    }, http.StatusGatewayTimeout, "upstream timed out")
+    registry.Register(errNotFound, http.StatusNotFound)
+
+    if m, ok := registry.Lookup(errors.New("timeout after 5s")); !ok || m.Status != http.StatusGatewayTimeout || m.Message != "upstream timed out" {
+        t.Errorf("unexpected mapping %+v, %v", m, ok)
+    }
+    if _, ok := registry.Lookup(errors.New("other")); ok {
+        t.Error("expected no mapping")
+    }
+
+    registry.DefaultStatus = http.StatusBadRequest
+    testTools := Tools{Errors: registry}
+    rr := httptest.NewRecorder()
+    _ = testTools.ErrorJSON(rr, errors.New("other"))
+    if rr.Code != http.StatusBadRequest {
+        t.Errorf("expected default status 400, got %d", rr.Code)
+    }
+}
+
+func TestTools_ErrorJSONRedact(t *testing.T) {
+    var logs bytes.Buffer
+    testTools := Tools{
+        RedactServerErrors: true,
+        Logger:             slog.New(slog.NewJSONHandler(&logs, nil)),
+    }
+
+    rr := httptest.NewRecorder()
+    secret := errors.New(`pq: relation "users" does not exist at /srv/app/db.go:42`)
+    if err := testTools.ErrorJSON(rr, secret, http.StatusInternalServerError); err != nil {
+        t.Fatal(err)
+    }
+
+    id := rr.Header().Get(DefaultCorrelationIDHeader)
+    if id == "" {
+        t.Fatal("expected correlation id header")
+    }
+    if strings.Contains(rr.Body.String(), "pq:") {
+        t.Errorf("internal error leaked to client: %s", rr.Body.String())
+    }
+
+    var payload JSONResponse
+    if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+        t.Fatal("received error when decoding JSON:", err)
+    }
+    data, _ := payload.Data.(map[string]interface{})
+    if payload.Message != "Internal Server Error" || data["correlationId"] != id {
+        t.Errorf("unexpected payload %+v", payload)
+    }
+
+    var entry map[string]interface{}
+    if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+        t.Fatal(err)
+    }
+    if entry["correlation_id"] != id || entry["error"] != secret.Error() {
+        t.Errorf("unexpected log entry %v", entry)
+    }
+
+    // client errors are not redacted
+    rr = httptest.NewRecorder()
+    _ = testTools.ErrorJSON(rr, errors.New("bad input"))
+    if rr.Header().Get(DefaultCorrelationIDHeader) != "" || !strings.Contains(rr.Body.String(), "bad input") {
+        t.Errorf("client error should not be redacted: %s", rr.Body.String())
+    }
+}
+
+func TestTools_ErrorJSONRedactExistingID(t *testing.T) {
+    testTools := Tools{
+        RedactServerErrors:  true,
+        CorrelationIDHeader: "X-Request-ID",
+        ErrorFormat:         ErrorFormatProblem,
+        Logger:              slog.New(slog.NewTextHandler(io.Discard, nil)),
+    }
+
+    rr := httptest.NewRecorder()
+    rr.Header().Set("X-Request-ID", "req-123")
+    problem := NewProblem(http.StatusBadGateway, "payment provider unavailable")
+    if err := testTools.ErrorJSON(rr, problem); err != nil {
+        t.Fatal(err)
+    }
+
+    var body Problem
+    if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+        t.Fatal("received error when decoding JSON:", err)
+    }
+    if body.Detail != "payment provider unavailable" || body.Extensions["correlationId"] != "req-123" {
+        t.Errorf("unexpected problem %+v", body)
+    }
+}
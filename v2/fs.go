@@ -0,0 +1,218 @@
+package toolkit
+
+import (
+    "errors"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// Errors of the filesystem helpers, returned wrapped in an *fs.PathError naming the operation and path
+var (
+    ErrNotDirectory   = errors.New("not a directory")
+    ErrNotRegularFile = errors.New("not a regular file")
+)
+
+// rename is os.Rename, replaced by tests to simulate moves across devices
+var rename = os.Rename
+
+// EnsureDir creates a directory and all necessary parents with mode, 0755 if not given, unless
+// it exists. It fails with ErrNotDirectory if path exists but is not a directory
+func (t *Tools) EnsureDir(path string, mode ...os.FileMode) error {
+    perm := os.FileMode(0755)
+    if len(mode) > 0 {
+        perm = mode[0]
+    }
+
+    info, err := os.Stat(path)
+    if err == nil && !info.IsDir() {
+        err = syscall.ENOTDIR
+    }
+    if errors.Is(err, fs.ErrNotExist) {
+        err = os.MkdirAll(path, perm)
+    }
+    // path or one of its parents is a file
+    if errors.Is(err, syscall.ENOTDIR) {
+        return &fs.PathError{Op: "ensure dir", Path: path, Err: ErrNotDirectory}
+    }
+    return err
+}
+
+// WriteFileAtomic writes data to path with perm, replacing any existing file, so that readers
+// see either the previous or the new content and never a partially written file
+func (t *Tools) WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+    return writeFileAtomic(path, data, perm)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+    return replaceFile(path, perm, func(w io.Writer) error {
+        _, err := w.Write(data)
+        return err
+    })
+}
+
+// replaceFile writes a temporary file next to path with write, syncs it and renames it to path
+func replaceFile(path string, perm os.FileMode, write func(w io.Writer) error) error {
+    dir := filepath.Dir(path)
+    tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if err = write(tmp); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err = tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err = tmp.Close(); err != nil {
+        return err
+    }
+    if err = os.Chmod(tmp.Name(), perm); err != nil {
+        return err
+    }
+    if err = rename(tmp.Name(), path); err != nil {
+        return err
+    }
+    syncDir(dir)
+    return nil
+}
+
+// syncDir flushes a directory entry change to disk, where the platform supports it
+func syncDir(dir string) {
+    if d, err := os.Open(dir); err == nil {
+        _ = d.Sync()
+        _ = d.Close()
+    }
+}
+
+// CopyFile copies the regular file src to dst with the same permissions, replacing dst
+// atomically if it exists. It fails with ErrNotRegularFile if src is not a regular file
+func (t *Tools) CopyFile(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    info, err := in.Stat()
+    if err != nil {
+        return err
+    }
+    if !info.Mode().IsRegular() {
+        return &fs.PathError{Op: "copy", Path: src, Err: ErrNotRegularFile}
+    }
+
+    return replaceFile(dst, info.Mode().Perm(), func(w io.Writer) error {
+        _, err := io.Copy(w, in)
+        return err
+    })
+}
+
+// MoveFile moves src to dst, replacing dst if it exists. When src and dst are on different
+// devices, where a rename is impossible, a regular file is copied and then removed
+func (t *Tools) MoveFile(src, dst string) error {
+    err := rename(src, dst)
+    if err == nil || !errors.Is(err, syscall.EXDEV) {
+        return err
+    }
+
+    if err = t.CopyFile(src, dst); err != nil {
+        return err
+    }
+    return os.Remove(src)
+}
+
+// DirSize returns the total size of the regular files in path and its subdirectories.
+// Symbolic links are not followed
+func (t *Tools) DirSize(path string) (int64, error) {
+    info, err := os.Stat(path)
+    if err != nil {
+        return 0, err
+    }
+    if !info.IsDir() {
+        return 0, &fs.PathError{Op: "dir size", Path: path, Err: ErrNotDirectory}
+    }
+
+    var size int64
+    err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if !d.Type().IsRegular() {
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        size += info.Size()
+        return nil
+    })
+    return size, err
+}
+
+// TempDir creates a new temporary directory, named as with os.MkdirTemp, and returns it with
+// a function removing it and its content
+func (t *Tools) TempDir(pattern string) (string, func() error, error) {
+    dir, err := os.MkdirTemp("", pattern)
+    if err != nil {
+        return "", nil, err
+    }
+    return dir, func() error { return os.RemoveAll(dir) }, nil
+}
+
+// RemoveStaleTempDirs removes the directories of parent, os.TempDir() if empty, whose name
+// matches pattern, as given to TempDir, and which were not modified for olderThan. Such
+// directories are left behind by processes which crashed before cleaning up. It returns the
+// number of directories removed
+func (t *Tools) RemoveStaleTempDirs(parent, pattern string, olderThan time.Duration) (int, error) {
+    if parent == "" {
+        parent = os.TempDir()
+    }
+    if !strings.Contains(pattern, "*") {
+        pattern += "*"
+    }
+
+    entries, err := os.ReadDir(parent)
+    if err != nil {
+        return 0, err
+    }
+
+    var (
+        removed  int
+        firstErr error
+    )
+    cutoff := time.Now().Add(-olderThan)
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            continue
+        }
+        if ok, err := filepath.Match(pattern, entry.Name()); err != nil {
+            return removed, err
+        } else if !ok {
+            continue
+        }
+
+        info, err := entry.Info()
+        if err != nil || !info.ModTime().Before(cutoff) {
+            continue
+        }
+        if err = os.RemoveAll(filepath.Join(parent, entry.Name())); err != nil {
+            if firstErr == nil {
+                firstErr = err
+            }
+            continue
+        }
+        removed++
+    }
+    return removed, firstErr
+}
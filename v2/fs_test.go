@@ -0,0 +1,228 @@
+package toolkit
+
+import (
+    "errors"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "runtime"
+    "syscall"
+    "testing"
+    "time"
+)
+
+func TestTools_EnsureDir(t *testing.T) {
+    var testTools Tools
+    root := t.TempDir()
+    file := filepath.Join(root, "file")
+    if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    var tests = []struct {
+        name          string
+        path          string
+        notDirectory  bool
+        errorExpected bool
+    }{
+        {name: "new", path: filepath.Join(root, "a", "b", "c"), errorExpected: false},
+        {name: "existing", path: filepath.Join(root, "a"), errorExpected: false},
+        {name: "file", path: file, notDirectory: true, errorExpected: true},
+        {name: "under a file", path: filepath.Join(file, "sub"), notDirectory: true, errorExpected: true},
+    }
+    for _, test := range tests {
+        err := testTools.EnsureDir(test.path)
+        if err == nil && test.errorExpected {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+        if err != nil && !test.errorExpected {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        var pathError *fs.PathError
+        if test.notDirectory && (!errors.Is(err, ErrNotDirectory) || !errors.As(err, &pathError)) {
+            t.Errorf("%s: expected a path error wrapping ErrNotDirectory, got %v", test.name, err)
+        }
+    }
+
+    if err := testTools.CreateDirIfNotExist(file); !errors.Is(err, ErrNotDirectory) {
+        t.Errorf("CreateDirIfNotExist: expected ErrNotDirectory, got %v", err)
+    }
+
+    if runtime.GOOS != "windows" {
+        private := filepath.Join(root, "private")
+        if err := testTools.EnsureDir(private, 0700); err != nil {
+            t.Fatal(err)
+        }
+        if info, _ := os.Stat(private); info.Mode().Perm() != 0700 {
+            t.Errorf("expected mode 0700, got %v", info.Mode().Perm())
+        }
+    }
+}
+
+func TestTools_WriteFileAtomic(t *testing.T) {
+    var testTools Tools
+    dir := t.TempDir()
+    path := filepath.Join(dir, "config.json")
+
+    for _, content := range []string{"first", "second"} {
+        if err := testTools.WriteFileAtomic(path, []byte(content), 0600); err != nil {
+            t.Fatal(err)
+        }
+        data, _ := os.ReadFile(path)
+        if string(data) != content {
+            t.Errorf("expected %q, got %q", content, data)
+        }
+    }
+
+    entries, _ := os.ReadDir(dir)
+    if len(entries) != 1 {
+        t.Errorf("temporary files left behind: %v", entries)
+    }
+    if err := testTools.WriteFileAtomic(filepath.Join(dir, "missing", "file"), nil, 0600); err == nil {
+        t.Error("error expected, but none received")
+    }
+}
+
+func TestTools_CopyFile(t *testing.T) {
+    var testTools Tools
+    dir := t.TempDir()
+    src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+    if err := os.WriteFile(src, []byte("content"), 0640); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(dst, []byte("previous content"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := testTools.CopyFile(src, dst); err != nil {
+        t.Fatal(err)
+    }
+    data, _ := os.ReadFile(dst)
+    if string(data) != "content" {
+        t.Errorf("unexpected content %q", data)
+    }
+    if info, _ := os.Stat(dst); runtime.GOOS != "windows" && info.Mode().Perm() != 0640 {
+        t.Errorf("mode not copied: %v", info.Mode().Perm())
+    }
+
+    if err := testTools.CopyFile(dir, dst); !errors.Is(err, ErrNotRegularFile) {
+        t.Errorf("directory: expected ErrNotRegularFile, got %v", err)
+    }
+    if err := testTools.CopyFile(filepath.Join(dir, "missing"), dst); !errors.Is(err, fs.ErrNotExist) {
+        t.Errorf("missing: expected fs.ErrNotExist, got %v", err)
+    }
+}
+
+func TestTools_MoveFile(t *testing.T) {
+    var testTools Tools
+
+    for _, crossDevice := range []bool{false, true} {
+        dir := t.TempDir()
+        src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+        if err := os.WriteFile(src, []byte("content"), 0644); err != nil {
+            t.Fatal(err)
+        }
+
+        if crossDevice {
+            // the first rename, of src, fails as it would across devices
+            rename = func(oldpath, newpath string) error {
+                if oldpath == src {
+                    return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+                }
+                return os.Rename(oldpath, newpath)
+            }
+        }
+        err := testTools.MoveFile(src, dst)
+        rename = os.Rename
+        if err != nil {
+            t.Fatalf("cross device %v: %v", crossDevice, err)
+        }
+
+        if _, err = os.Stat(src); !errors.Is(err, fs.ErrNotExist) {
+            t.Errorf("cross device %v: source not removed", crossDevice)
+        }
+        if data, _ := os.ReadFile(dst); string(data) != "content" {
+            t.Errorf("cross device %v: unexpected content %q", crossDevice, data)
+        }
+    }
+}
+
+func TestTools_DirSize(t *testing.T) {
+    var testTools Tools
+    dir := t.TempDir()
+
+    files := map[string]int{"a": 10, "sub/b": 20, "sub/deeper/c": 30}
+    for name, size := range files {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        _ = os.MkdirAll(filepath.Dir(path), 0755)
+        if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if runtime.GOOS != "windows" {
+        _ = os.Symlink(filepath.Join(dir, "sub"), filepath.Join(dir, "link"))
+    }
+
+    size, err := testTools.DirSize(dir)
+    if err != nil || size != 60 {
+        t.Errorf("expected 60 bytes, got %d: %v", size, err)
+    }
+    if _, err = testTools.DirSize(filepath.Join(dir, "a")); !errors.Is(err, ErrNotDirectory) {
+        t.Errorf("file: expected ErrNotDirectory, got %v", err)
+    }
+}
+
+func TestTools_TempDir(t *testing.T) {
+    var testTools Tools
+
+    dir, cleanup, err := testTools.TempDir("toolkit-test-*")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err = cleanup(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err = os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+        t.Error("temporary directory not removed")
+    }
+}
+
+func TestTools_RemoveStaleTempDirs(t *testing.T) {
+    var testTools Tools
+    parent := t.TempDir()
+
+    old := time.Now().Add(-2 * time.Hour)
+    for _, name := range []string{"upload-1", "upload-2", "upload-new", "other-1"} {
+        path := filepath.Join(parent, name)
+        if err := os.MkdirAll(filepath.Join(path, "nested"), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if name != "upload-new" {
+            _ = os.Chtimes(path, old, old)
+        }
+    }
+    // files are never removed, even when they match
+    _ = os.WriteFile(filepath.Join(parent, "upload-file"), nil, 0644)
+    _ = os.Chtimes(filepath.Join(parent, "upload-file"), old, old)
+
+    removed, err := testTools.RemoveStaleTempDirs(parent, "upload-", time.Hour)
+    if err != nil || removed != 2 {
+        t.Errorf("expected 2 directories removed, got %d: %v", removed, err)
+    }
+
+    entries, _ := os.ReadDir(parent)
+    var names []string
+    for _, entry := range entries {
+        names = append(names, entry.Name())
+    }
+    if len(names) != 3 {
+        t.Errorf("unexpected remaining entries %v", names)
+    }
+
+    if _, err = testTools.RemoveStaleTempDirs(parent, "[", time.Hour); err == nil {
+        t.Error("malformed pattern: error expected, but none received")
+    }
+}
@@ -1,3 +1,3 @@
 module github.com/tiqet/toolkit/v2
 
-go 1.19
+go 1.21
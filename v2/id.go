@@ -0,0 +1,210 @@
+package toolkit
+
+import (
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+)
+
+// ErrInvalidID is returned when a UUID or ULID cannot be parsed
+var ErrInvalidID = errors.New("invalid id")
+
+// UUID is a universally unique identifier as defined by RFC 9562
+type UUID [16]byte
+
+// NewUUIDv4 returns a random UUID
+func (t *Tools) NewUUIDv4() (UUID, error) {
+    var u UUID
+    if _, err := io.ReadFull(randReader, u[:]); err != nil {
+        return u, fmt.Errorf("error reading random bytes: %w", err)
+    }
+    u.setVersion(4)
+    return u, nil
+}
+
+// NewUUIDv7 returns a UUID starting with the current unix time in milliseconds, followed by
+// random bits, so that UUIDs sort by creation time to the millisecond
+func (t *Tools) NewUUIDv7() (UUID, error) {
+    var u UUID
+    if _, err := io.ReadFull(randReader, u[6:]); err != nil {
+        return u, fmt.Errorf("error reading random bytes: %w", err)
+    }
+    putMillis(u[:6], time.Now())
+    u.setVersion(7)
+    return u, nil
+}
+
+// setVersion sets the version and the RFC 9562 variant bits
+func (u *UUID) setVersion(version byte) {
+    u[6] = u[6]&0x0f | version<<4
+    u[8] = u[8]&0x3f | 0x80
+}
+
+// ParseUUID parses a UUID in its canonical form, xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, or as
+// 32 hexadecimal digits. Upper and lower case are accepted
+func ParseUUID(s string) (UUID, error) {
+    var u UUID
+    switch len(s) {
+    case 36:
+        if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+            return u, fmt.Errorf("%w: malformed uuid %q", ErrInvalidID, s)
+        }
+        s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+    case 32:
+    default:
+        return u, fmt.Errorf("%w: malformed uuid %q", ErrInvalidID, s)
+    }
+    if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+        return u, fmt.Errorf("%w: malformed uuid %q", ErrInvalidID, s)
+    }
+    return u, nil
+}
+
+// String returns the canonical form of u, in lower case
+func (u UUID) String() string {
+    var buf [36]byte
+    hex.Encode(buf[0:8], u[0:4])
+    buf[8] = '-'
+    hex.Encode(buf[9:13], u[4:6])
+    buf[13] = '-'
+    hex.Encode(buf[14:18], u[6:8])
+    buf[18] = '-'
+    hex.Encode(buf[19:23], u[8:10])
+    buf[23] = '-'
+    hex.Encode(buf[24:], u[10:])
+    return string(buf[:])
+}
+
+// Version returns the version of u, 4 or 7 for the UUIDs made by this package
+func (u UUID) Version() int {
+    return int(u[6] >> 4)
+}
+
+// Time returns the creation time of a version 7 UUID, or the zero time for other versions
+func (u UUID) Time() time.Time {
+    if u.Version() != 7 {
+        return time.Time{}
+    }
+    return millisTime(u[:6])
+}
+
+// MarshalText encodes u in its canonical form, for use in JSON
+func (u UUID) MarshalText() ([]byte, error) {
+    return []byte(u.String()), nil
+}
+
+// UnmarshalText parses a UUID with ParseUUID
+func (u *UUID) UnmarshalText(data []byte) error {
+    parsed, err := ParseUUID(string(data))
+    if err != nil {
+        return err
+    }
+    *u = parsed
+    return nil
+}
+
+// ULID is a universally unique lexicographically sortable identifier: 48 bits of unix time
+// in milliseconds followed by 80 random bits, written as 26 Crockford base32 characters
+type ULID [16]byte
+
+// NewULID returns a ULID for the current time
+func (t *Tools) NewULID() (ULID, error) {
+    var u ULID
+    if _, err := io.ReadFull(randReader, u[6:]); err != nil {
+        return u, fmt.Errorf("error reading random bytes: %w", err)
+    }
+    putMillis(u[:6], time.Now())
+    return u, nil
+}
+
+// ParseULID parses the 26 character form of a ULID. Lower case is accepted, and I, L and O
+// are read as 1, 1 and 0 as Crockford base32 allows
+func ParseULID(s string) (ULID, error) {
+    var u ULID
+    if len(s) != 26 {
+        return u, fmt.Errorf("%w: malformed ulid %q", ErrInvalidID, s)
+    }
+
+    // 26 characters hold 130 bits, of which the first 2 must be zero
+    var hi, lo uint64
+    for i := 0; i < len(s); i++ {
+        v := crockfordValue(s[i])
+        if v < 0 || (i == 0 && v > 7) {
+            return u, fmt.Errorf("%w: malformed ulid %q", ErrInvalidID, s)
+        }
+        hi = hi<<5 | lo>>59
+        lo = lo<<5 | uint64(v)
+    }
+    binary.BigEndian.PutUint64(u[:8], hi)
+    binary.BigEndian.PutUint64(u[8:], lo)
+    return u, nil
+}
+
+// crockfordValue returns the value of a Crockford base32 digit, or -1
+func crockfordValue(c byte) int {
+    if c >= 'a' && c <= 'z' {
+        c -= 'a' - 'A'
+    }
+    switch c {
+    case 'I', 'L':
+        return 1
+    case 'O':
+        return 0
+    }
+    return strings.IndexByte(AlphabetCrockford, c)
+}
+
+// String returns the 26 character form of u
+func (u ULID) String() string {
+    hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+
+    var buf [26]byte
+    for i := len(buf) - 1; i >= 0; i-- {
+        buf[i] = AlphabetCrockford[lo&0x1f]
+        lo = lo>>5 | hi<<59
+        hi >>= 5
+    }
+    return string(buf[:])
+}
+
+// Time returns the creation time of u
+func (u ULID) Time() time.Time {
+    return millisTime(u[:6])
+}
+
+// MarshalText encodes u in its 26 character form, for use in JSON
+func (u ULID) MarshalText() ([]byte, error) {
+    return []byte(u.String()), nil
+}
+
+// UnmarshalText parses a ULID with ParseULID
+func (u *ULID) UnmarshalText(data []byte) error {
+    parsed, err := ParseULID(string(data))
+    if err != nil {
+        return err
+    }
+    *u = parsed
+    return nil
+}
+
+// putMillis writes the unix time of t in milliseconds to the 6 bytes of b, big endian
+func putMillis(b []byte, t time.Time) {
+    ms := uint64(t.UnixMilli())
+    for i := 5; i >= 0; i-- {
+        b[i] = byte(ms)
+        ms >>= 8
+    }
+}
+
+// millisTime reads the time written by putMillis
+func millisTime(b []byte) time.Time {
+    var ms int64
+    for _, c := range b[:6] {
+        ms = ms<<8 | int64(c)
+    }
+    return time.UnixMilli(ms)
+}
@@ -0,0 +1,197 @@
+package toolkit
+
+import (
+    "crypto/rand"
+    "encoding/json"
+    "errors"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestTools_NewUUID(t *testing.T) {
+    var testTools Tools
+
+    before := time.Now().Truncate(time.Millisecond)
+    v4, err := testTools.NewUUIDv4()
+    if err != nil {
+        t.Fatal(err)
+    }
+    v7, err := testTools.NewUUIDv7()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, test := range []struct {
+        uuid    UUID
+        version int
+    }{{v4, 4}, {v7, 7}} {
+        if test.uuid.Version() != test.version {
+            t.Errorf("expected version %d, got %d", test.version, test.uuid.Version())
+        }
+        // RFC 9562 variant
+        if test.uuid[8]>>6 != 2 {
+            t.Errorf("wrong variant in %s", test.uuid)
+        }
+        parsed, err := ParseUUID(test.uuid.String())
+        if err != nil || parsed != test.uuid {
+            t.Errorf("%s does not round trip: %v", test.uuid, err)
+        }
+    }
+
+    if created := v7.Time(); created.Before(before) || created.After(time.Now()) {
+        t.Errorf("unexpected time %v in uuid v7", created)
+    }
+    if !v4.Time().IsZero() {
+        t.Error("uuid v4 has a time")
+    }
+}
+
+func TestTools_NewUUIDv7_Ordered(t *testing.T) {
+    var testTools Tools
+
+    var ids []string
+    for i := 0; i < 5; i++ {
+        u, _ := testTools.NewUUIDv7()
+        ids = append(ids, u.String())
+        time.Sleep(2 * time.Millisecond)
+    }
+    if !sort.StringsAreSorted(ids) {
+        t.Errorf("uuids are not time ordered: %v", ids)
+    }
+}
+
+var parseUUIDTests = []struct {
+    name          string
+    input         string
+    errorExpected bool
+}{
+    {name: "canonical", input: "0190a5b2-7c3e-7d4f-8a1b-2c3d4e5f6a7b", errorExpected: false},
+    {name: "upper case", input: "0190A5B2-7C3E-7D4F-8A1B-2C3D4E5F6A7B", errorExpected: false},
+    {name: "no hyphens", input: "0190a5b27c3e7d4f8a1b2c3d4e5f6a7b", errorExpected: false},
+    {name: "misplaced hyphen", input: "0190a5b27-c3e-7d4f-8a1b-2c3d4e5f6a7b", errorExpected: true},
+    {name: "not hex", input: "0190a5b2-7c3e-7d4f-8a1b-2c3d4e5f6a7z", errorExpected: true},
+    {name: "too short", input: "0190a5b2-7c3e", errorExpected: true},
+    {name: "empty", input: "", errorExpected: true},
+}
+
+func TestParseUUID(t *testing.T) {
+    for _, test := range parseUUIDTests {
+        u, err := ParseUUID(test.input)
+        if err == nil && test.errorExpected {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+        if err != nil && !test.errorExpected {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        if err != nil && !errors.Is(err, ErrInvalidID) {
+            t.Errorf("%s: error does not wrap ErrInvalidID", test.name)
+        }
+        if err == nil && u.String() != "0190a5b2-7c3e-7d4f-8a1b-2c3d4e5f6a7b" {
+            t.Errorf("%s: unexpected uuid %s", test.name, u)
+        }
+    }
+}
+
+func TestTools_NewULID(t *testing.T) {
+    var testTools Tools
+
+    before := time.Now().Truncate(time.Millisecond)
+    u, err := testTools.NewULID()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    s := u.String()
+    if len(s) != 26 || strings.Trim(s, AlphabetCrockford) != "" {
+        t.Errorf("malformed ulid %s", s)
+    }
+    if created := u.Time(); created.Before(before) || created.After(time.Now()) {
+        t.Errorf("unexpected time %v in ulid", created)
+    }
+
+    parsed, err := ParseULID(strings.ToLower(s))
+    if err != nil || parsed != u {
+        t.Errorf("%s does not round trip: %v", s, err)
+    }
+}
+
+var parseULIDTests = []struct {
+    name          string
+    input         string
+    expected      string
+    errorExpected bool
+}{
+    {name: "canonical", input: "01ARZ3NDEKTSV4RRFFQ69G5FAV", expected: "01ARZ3NDEKTSV4RRFFQ69G5FAV", errorExpected: false},
+    {name: "lower case", input: "01arz3ndektsv4rrffq69g5fav", expected: "01ARZ3NDEKTSV4RRFFQ69G5FAV", errorExpected: false},
+    {name: "lookalikes", input: "O1ARZ3NDEKTSV4RRFFQ69G5FAV", expected: "01ARZ3NDEKTSV4RRFFQ69G5FAV", errorExpected: false},
+    {name: "max", input: "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", expected: "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", errorExpected: false},
+    {name: "overflow", input: "8ZZZZZZZZZZZZZZZZZZZZZZZZZ", errorExpected: true},
+    {name: "invalid character", input: "01ARZ3NDEKTSV4RRFFQ69G5FAU", errorExpected: true},
+    {name: "too short", input: "01ARZ3NDEK", errorExpected: true},
+}
+
+func TestParseULID(t *testing.T) {
+    for _, test := range parseULIDTests {
+        u, err := ParseULID(test.input)
+        if err == nil && test.errorExpected {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+        if err != nil && !test.errorExpected {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        if err == nil && u.String() != test.expected {
+            t.Errorf("%s: expected %s, got %s", test.name, test.expected, u)
+        }
+    }
+
+    // the timestamp of the example ULID of the specification
+    u, _ := ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+    if ms := u.Time().UnixMilli(); ms != 1469922850259 {
+        t.Errorf("unexpected timestamp %d", ms)
+    }
+}
+
+func TestID_JSON(t *testing.T) {
+    var testTools Tools
+    u, _ := testTools.NewUUIDv7()
+    l, _ := testTools.NewULID()
+
+    type record struct {
+        UUID UUID `json:"uuid"`
+        ULID ULID `json:"ulid"`
+    }
+    data, err := json.Marshal(record{UUID: u, ULID: l})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(data), `"`+u.String()+`"`) || !strings.Contains(string(data), `"`+l.String()+`"`) {
+        t.Errorf("unexpected encoding %s", data)
+    }
+
+    var decoded record
+    if err = json.Unmarshal(data, &decoded); err != nil || decoded.UUID != u || decoded.ULID != l {
+        t.Errorf("ids do not round trip: %v", err)
+    }
+    if err = json.Unmarshal([]byte(`{"uuid":"nope"}`), &decoded); err == nil {
+        t.Error("error expected, but none received")
+    }
+}
+
+func TestID_EntropyFailure(t *testing.T) {
+    var testTools Tools
+
+    randReader = failingReader{}
+    defer func() { randReader = rand.Reader }()
+
+    if _, err := testTools.NewUUIDv4(); err == nil {
+        t.Error("uuid v4: error expected, but none received")
+    }
+    if _, err := testTools.NewUUIDv7(); err == nil {
+        t.Error("uuid v7: error expected, but none received")
+    }
+    if _, err := testTools.NewULID(); err == nil {
+        t.Error("ulid: error expected, but none received")
+    }
+}
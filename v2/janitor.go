@@ -0,0 +1,210 @@
+package toolkit
+
+import (
+    "context"
+    "errors"
+    "io/fs"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// UploadJanitor removes files which are no longer needed from an upload directory: expired
+// files, orphans which were uploaded but never attached to a record, and the temporary files
+// of WriteFileAtomic and CopyFile left behind by interrupted writes
+type UploadJanitor struct {
+    // Dir is the upload directory, scanned recursively
+    Dir string
+    // MaxAge is the age after which files are removed even if kept; no limit if zero
+    MaxAge time.Duration
+    // OrphanAge is how long files not kept are given to be attached to a record, 1h if zero
+    OrphanAge time.Duration
+    // PartialAge is the age after which temporary files of interrupted writes are removed, 10 minutes if zero
+    PartialAge time.Duration
+    // Keep reports whether a file, named by its slash-separated path relative to Dir, is still
+    // in use. When nil, files are only removed by MaxAge and PartialAge
+    Keep func(ctx context.Context, name string) (bool, error)
+    // Interval is the time between sweeps made by Run, 1h if zero
+    Interval time.Duration
+    // DryRun reports and logs the files which would be removed without removing them
+    DryRun bool
+
+    tools *Tools
+    now   func() time.Time
+}
+
+// JanitorReport describes a sweep of an UploadJanitor
+type JanitorReport struct {
+    // Scanned is the number of files examined
+    Scanned int
+    // Removed lists the files removed, or which would have been in a dry run, relative to Dir
+    Removed []string
+    // RemovedBytes is the total size of the removed files
+    RemovedBytes int64
+}
+
+// NewUploadJanitor returns a janitor for dir, logging with the Logger of the Tools
+func (t *Tools) NewUploadJanitor(dir string) *UploadJanitor {
+    return &UploadJanitor{Dir: dir, tools: t}
+}
+
+// Run sweeps the directory every Interval until ctx is done. Errors are logged, and do not
+// stop the janitor
+func (j *UploadJanitor) Run(ctx context.Context) error {
+    interval := j.Interval
+    if interval <= 0 {
+        interval = time.Hour
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        if _, err := j.Sweep(ctx); err != nil && ctx.Err() == nil {
+            j.logger().Error("upload janitor sweep failed", "dir", j.Dir, "error", err.Error())
+        }
+
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-ticker.C:
+        }
+    }
+}
+
+// Sweep scans the directory once and removes the files which are no longer needed.
+// It stops with ctx.Err() when ctx is done. Errors on single files, including those of Keep,
+// do not stop the sweep; the first one is returned with the report
+func (j *UploadJanitor) Sweep(ctx context.Context) (JanitorReport, error) {
+    var (
+        report   JanitorReport
+        firstErr error
+    )
+    now := time.Now()
+    if j.now != nil {
+        now = j.now()
+    }
+
+    err := filepath.WalkDir(j.Dir, func(path string, d fs.DirEntry, err error) error {
+        if ctxErr := ctx.Err(); ctxErr != nil {
+            return ctxErr
+        }
+        if err != nil {
+            if path == j.Dir {
+                return err
+            }
+            if firstErr == nil {
+                firstErr = err
+            }
+            return nil
+        }
+        if !d.Type().IsRegular() {
+            return nil
+        }
+
+        info, err := d.Info()
+        if err != nil {
+            if firstErr == nil && !errors.Is(err, fs.ErrNotExist) {
+                firstErr = err
+            }
+            return nil
+        }
+        report.Scanned++
+
+        rel, _ := filepath.Rel(j.Dir, path)
+        name := filepath.ToSlash(rel)
+        reason, err := j.removalReason(ctx, name, now.Sub(info.ModTime()))
+        if err != nil {
+            if firstErr == nil {
+                firstErr = err
+            }
+            return nil
+        }
+        if reason == "" {
+            return nil
+        }
+
+        if !j.DryRun {
+            if err = j.remove(path); err != nil {
+                if firstErr == nil {
+                    firstErr = err
+                }
+                return nil
+            }
+        }
+        j.logger().Info("upload janitor removed file", "file", name, "reason", reason, "size", info.Size(), "dry_run", j.DryRun)
+        report.Removed = append(report.Removed, name)
+        report.RemovedBytes += info.Size()
+        return nil
+    })
+    if err != nil {
+        return report, err
+    }
+    return report, firstErr
+}
+
+// removalReason returns why the file name of the given age must be removed, or an empty string
+func (j *UploadJanitor) removalReason(ctx context.Context, name string, age time.Duration) (string, error) {
+    if partialFile(name) {
+        partialAge := j.PartialAge
+        if partialAge <= 0 {
+            partialAge = 10 * time.Minute
+        }
+        if age > partialAge {
+            return "partial", nil
+        }
+        return "", nil
+    }
+
+    if j.MaxAge > 0 && age > j.MaxAge {
+        return "expired", nil
+    }
+
+    orphanAge := j.OrphanAge
+    if orphanAge <= 0 {
+        orphanAge = time.Hour
+    }
+    if j.Keep == nil || age <= orphanAge {
+        return "", nil
+    }
+    keep, err := j.Keep(ctx, name)
+    if err != nil || keep {
+        return "", err
+    }
+    return "orphan", nil
+}
+
+// remove deletes the file at path, unless it is already gone
+func (j *UploadJanitor) remove(path string) error {
+    err := os.Remove(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        // removed concurrently
+        return nil
+    }
+    return err
+}
+
+func (j *UploadJanitor) logger() *slog.Logger {
+    if j.tools == nil {
+        return slog.Default()
+    }
+    return j.tools.logger()
+}
+
+// partialFile reports whether name is a temporary file left behind by an interrupted
+// WriteFileAtomic or CopyFile, named .<name>.tmp<random digits>. Uploads keep the extension
+// of the client, so other names such as .part or .tmp files are treated as any upload
+func partialFile(name string) bool {
+    base := filepath.Base(name)
+    i := strings.LastIndex(base, ".tmp")
+    if !strings.HasPrefix(base, ".") || i <= 0 || i+len(".tmp") == len(base) {
+        return false
+    }
+    for _, r := range base[i+len(".tmp"):] {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
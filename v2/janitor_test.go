@@ -0,0 +1,188 @@
+package toolkit
+
+import (
+    "context"
+    "errors"
+    "io"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+)
+
+// janitorFiles are created with the given age by newJanitorDir
+var janitorFiles = map[string]time.Duration{
+    "fresh.png":             time.Minute,
+    "attached.png":          2 * time.Hour,
+    "orphan.png":            2 * time.Hour,
+    "old-attached.png":      48 * time.Hour,
+    "sub/orphan.jpg":        3 * time.Hour,
+    "sub/attached.jpg":      3 * time.Hour,
+    "attached.part":         2 * time.Hour,
+    ".config.json.tmp12345": time.Hour,
+    ".fresh.png.tmp678":     time.Minute,
+}
+
+func newJanitorDir(t *testing.T) string {
+    dir := t.TempDir()
+    now := time.Now()
+    for name, age := range janitorFiles {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        _ = os.MkdirAll(filepath.Dir(path), 0755)
+        if err := os.WriteFile(path, []byte("12345"), 0644); err != nil {
+            t.Fatal(err)
+        }
+        _ = os.Chtimes(path, now.Add(-age), now.Add(-age))
+    }
+    return dir
+}
+
+func keepAttached(_ context.Context, name string) (bool, error) {
+    return strings.Contains(name, "attached"), nil
+}
+
+var janitorTests = []struct {
+    name     string
+    maxAge   time.Duration
+    keep     func(context.Context, string) (bool, error)
+    dryRun   bool
+    expected []string
+}{
+    {
+        name:     "partial files only",
+        expected: []string{".config.json.tmp12345"},
+    },
+    {
+        name:     "orphans",
+        keep:     keepAttached,
+        expected: []string{".config.json.tmp12345", "orphan.png", "sub/orphan.jpg"},
+    },
+    {
+        name:     "orphans and expired",
+        maxAge:   24 * time.Hour,
+        keep:     keepAttached,
+        expected: []string{".config.json.tmp12345", "old-attached.png", "orphan.png", "sub/orphan.jpg"},
+    },
+    {
+        name:     "dry run",
+        maxAge:   24 * time.Hour,
+        keep:     keepAttached,
+        dryRun:   true,
+        expected: []string{".config.json.tmp12345", "old-attached.png", "orphan.png", "sub/orphan.jpg"},
+    },
+}
+
+func TestUploadJanitor_Sweep(t *testing.T) {
+    testTools := Tools{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+    for _, test := range janitorTests {
+        dir := newJanitorDir(t)
+        janitor := testTools.NewUploadJanitor(dir)
+        janitor.MaxAge, janitor.Keep, janitor.DryRun = test.maxAge, test.keep, test.dryRun
+
+        report, err := janitor.Sweep(context.Background())
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", test.name, err)
+            continue
+        }
+
+        sort.Strings(report.Removed)
+        if strings.Join(report.Removed, ",") != strings.Join(test.expected, ",") {
+            t.Errorf("%s: expected %v removed, got %v", test.name, test.expected, report.Removed)
+        }
+        if report.Scanned != len(janitorFiles) || report.RemovedBytes != int64(5*len(test.expected)) {
+            t.Errorf("%s: unexpected report %+v", test.name, report)
+        }
+
+        removed := make(map[string]bool)
+        for _, name := range test.expected {
+            removed[name] = !test.dryRun
+        }
+        for name := range janitorFiles {
+            _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+            if os.IsNotExist(err) != removed[name] {
+                t.Errorf("%s: %s removed %v, expected %v", test.name, name, os.IsNotExist(err), removed[name])
+            }
+        }
+    }
+}
+
+func TestUploadJanitor_KeepError(t *testing.T) {
+    testTools := Tools{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+    janitor := testTools.NewUploadJanitor(newJanitorDir(t))
+
+    failure := errors.New("database is down")
+    janitor.Keep = func(context.Context, string) (bool, error) { return false, failure }
+
+    report, err := janitor.Sweep(context.Background())
+    if !errors.Is(err, failure) {
+        t.Errorf("expected the error of Keep, got %v", err)
+    }
+    // no orphans are removed when they cannot be checked, but partial files are
+    if len(report.Removed) != 1 {
+        t.Errorf("unexpected files removed: %v", report.Removed)
+    }
+}
+
+var partialFileTests = []struct {
+    name     string
+    expected bool
+}{
+    {name: ".report.pdf.tmp123456", expected: true},
+    {name: "sub/.a.tmp1", expected: true},
+    {name: "upload.part", expected: false},
+    {name: "upload.tmp", expected: false},
+    {name: "notes.txt~", expected: false},
+    {name: ".hidden.tmp", expected: false},
+    {name: ".report.tmp12ab", expected: false},
+}
+
+func TestPartialFile(t *testing.T) {
+    for _, test := range partialFileTests {
+        if partialFile(test.name) != test.expected {
+            t.Errorf("%s: expected partial %v", test.name, test.expected)
+        }
+    }
+}
+
+func TestUploadJanitor_MissingDir(t *testing.T) {
+    var testTools Tools
+    janitor := testTools.NewUploadJanitor(filepath.Join(t.TempDir(), "missing"))
+
+    if _, err := janitor.Sweep(context.Background()); !os.IsNotExist(err) {
+        t.Errorf("expected a not exist error, got %v", err)
+    }
+}
+
+func TestUploadJanitor_Run(t *testing.T) {
+    testTools := Tools{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+    dir := newJanitorDir(t)
+    janitor := testTools.NewUploadJanitor(dir)
+    janitor.Interval = 10 * time.Millisecond
+
+    // cancel once the first sweep asks about a file
+    ctx, cancel := context.WithCancel(context.Background())
+    janitor.Keep = func(context.Context, string) (bool, error) {
+        cancel()
+        return true, nil
+    }
+
+    done := make(chan error)
+    go func() { done <- janitor.Run(ctx) }()
+    select {
+    case err := <-done:
+        if !errors.Is(err, context.Canceled) {
+            t.Errorf("expected context.Canceled, got %v", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("janitor did not stop")
+    }
+
+    // the sweep stopped before reaching the other files
+    if report, _ := janitor.Sweep(ctx); report.Scanned != 0 {
+        t.Errorf("sweep did not stop on a cancelled context: %+v", report)
+    }
+}
@@ -0,0 +1,738 @@
+package toolkit
+
+import (
+    "bufio"
+    "encoding"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// MsgPackCodec encodes and decodes application/msgpack. Struct fields are named by their
+// `msgpack` tag, then their `json` tag, then their name, in both directions; "omitempty" is
+// honored. As in encoding/json, decoding falls back to a case-insensitive match of field names
+// and ignores unknown keys
+type MsgPackCodec struct{}
+
+// MediaTypes returns the media types handled by MsgPackCodec
+func (MsgPackCodec) MediaTypes() []string {
+    return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+// Encode writes v in MessagePack format
+func (MsgPackCodec) Encode(w io.Writer, v interface{}) error {
+    bw := bufio.NewWriter(w)
+    e := msgpackEncoder{w: bw}
+    if err := e.encode(reflect.ValueOf(v)); err != nil {
+        return err
+    }
+    return bw.Flush()
+}
+
+// Decode reads a single MessagePack value into v, which must be a non-nil pointer
+func (MsgPackCodec) Decode(r io.Reader, v interface{}) error {
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Pointer || rv.IsNil() {
+        return fmt.Errorf("msgpack: cannot decode into %T", v)
+    }
+
+    d := msgpackDecoder{r: bufio.NewReader(r)}
+    generic, err := d.decode()
+    if err != nil {
+        return err
+    }
+    return setMsgpackValue(rv.Elem(), generic)
+}
+
+type msgpackEncoder struct {
+    w   *bufio.Writer
+    buf [9]byte
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+    if !v.IsValid() {
+        return e.w.WriteByte(0xc0)
+    }
+    if v.Type().Implements(textMarshalerType) {
+        if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+            return e.w.WriteByte(0xc0)
+        }
+        text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+        if err != nil {
+            return err
+        }
+        return e.writeString(string(text))
+    }
+
+    switch v.Kind() {
+    case reflect.Pointer, reflect.Interface:
+        if v.IsNil() {
+            return e.w.WriteByte(0xc0)
+        }
+        return e.encode(v.Elem())
+    case reflect.Bool:
+        if v.Bool() {
+            return e.w.WriteByte(0xc3)
+        }
+        return e.w.WriteByte(0xc2)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return e.writeInt(v.Int())
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return e.writeUint(v.Uint())
+    case reflect.Float32:
+        e.buf[0] = 0xca
+        binary.BigEndian.PutUint32(e.buf[1:], math.Float32bits(float32(v.Float())))
+        return e.write(5)
+    case reflect.Float64:
+        e.buf[0] = 0xcb
+        binary.BigEndian.PutUint64(e.buf[1:], math.Float64bits(v.Float()))
+        return e.write(9)
+    case reflect.String:
+        return e.writeString(v.String())
+    case reflect.Slice, reflect.Array:
+        if v.Kind() == reflect.Slice && v.IsNil() {
+            return e.w.WriteByte(0xc0)
+        }
+        if v.Type().Elem().Kind() == reflect.Uint8 {
+            b := make([]byte, v.Len())
+            reflect.Copy(reflect.ValueOf(b), v)
+            return e.writeBinary(b)
+        }
+        if err := e.writeHeader(v.Len(), 0x90, 16, 0xdc, 0xdd); err != nil {
+            return err
+        }
+        for i := 0; i < v.Len(); i++ {
+            if err := e.encode(v.Index(i)); err != nil {
+                return err
+            }
+        }
+        return nil
+    case reflect.Map:
+        if v.IsNil() {
+            return e.w.WriteByte(0xc0)
+        }
+        keys := v.MapKeys()
+        sort.Slice(keys, func(i, j int) bool {
+            return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+        })
+        if err := e.writeHeader(len(keys), 0x80, 16, 0xde, 0xdf); err != nil {
+            return err
+        }
+        for _, key := range keys {
+            if err := e.encode(key); err != nil {
+                return err
+            }
+            if err := e.encode(v.MapIndex(key)); err != nil {
+                return err
+            }
+        }
+        return nil
+    case reflect.Struct:
+        var fields []msgpackField
+        collectMsgpackFields(v, &fields)
+        if err := e.writeHeader(len(fields), 0x80, 16, 0xde, 0xdf); err != nil {
+            return err
+        }
+        for _, f := range fields {
+            if err := e.writeString(f.name); err != nil {
+                return err
+            }
+            if err := e.encode(f.value); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+}
+
+// msgpackField is a named struct field value to be encoded
+type msgpackField struct {
+    name  string
+    value reflect.Value
+}
+
+// collectMsgpackFields gathers the encodable fields of struct v
+func collectMsgpackFields(v reflect.Value, fields *[]msgpackField) {
+    for _, f := range msgpackStructFields(v.Type(), nil) {
+        fv := v.FieldByIndex(f.index)
+        if f.omitEmpty && fv.IsZero() {
+            continue
+        }
+        *fields = append(*fields, msgpackField{name: f.name, value: fv})
+    }
+}
+
+// msgpackStructField is a struct field as named in MessagePack maps
+type msgpackStructField struct {
+    name      string
+    index     []int
+    omitEmpty bool
+}
+
+// msgpackStructFields returns the fields of typ, which is reached through index, inlining
+// untagged embedded structs. Encoding and decoding both name fields with it
+func msgpackStructFields(typ reflect.Type, index []int) []msgpackStructField {
+    var fields []msgpackStructField
+    for i := 0; i < typ.NumField(); i++ {
+        sf := typ.Field(i)
+        fieldIndex := append(append([]int(nil), index...), i)
+        if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("msgpack") == "" && sf.Tag.Get("json") == "" {
+            fields = append(fields, msgpackStructFields(sf.Type, fieldIndex)...)
+            continue
+        }
+        if !sf.IsExported() {
+            continue
+        }
+        name, ok := fieldName(sf, "msgpack", "json")
+        if !ok {
+            continue
+        }
+        tag := sf.Tag.Get("msgpack")
+        if tag == "" {
+            tag = sf.Tag.Get("json")
+        }
+        fields = append(fields, msgpackStructField{
+            name:      name,
+            index:     fieldIndex,
+            omitEmpty: strings.Contains(tag, ",omitempty"),
+        })
+    }
+    return fields
+}
+
+func (e *msgpackEncoder) write(n int) error {
+    _, err := e.w.Write(e.buf[:n])
+    return err
+}
+
+func (e *msgpackEncoder) writeInt(n int64) error {
+    switch {
+    case n >= 0:
+        return e.writeUint(uint64(n))
+    case n >= -32:
+        return e.w.WriteByte(byte(n))
+    case n >= math.MinInt8:
+        e.buf[0], e.buf[1] = 0xd0, byte(n)
+        return e.write(2)
+    case n >= math.MinInt16:
+        e.buf[0] = 0xd1
+        binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+        return e.write(3)
+    case n >= math.MinInt32:
+        e.buf[0] = 0xd2
+        binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+        return e.write(5)
+    }
+    e.buf[0] = 0xd3
+    binary.BigEndian.PutUint64(e.buf[1:], uint64(n))
+    return e.write(9)
+}
+
+func (e *msgpackEncoder) writeUint(n uint64) error {
+    switch {
+    case n <= 0x7f:
+        return e.w.WriteByte(byte(n))
+    case n <= math.MaxUint8:
+        e.buf[0], e.buf[1] = 0xcc, byte(n)
+        return e.write(2)
+    case n <= math.MaxUint16:
+        e.buf[0] = 0xcd
+        binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+        return e.write(3)
+    case n <= math.MaxUint32:
+        e.buf[0] = 0xce
+        binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+        return e.write(5)
+    }
+    e.buf[0] = 0xcf
+    binary.BigEndian.PutUint64(e.buf[1:], n)
+    return e.write(9)
+}
+
+func (e *msgpackEncoder) writeString(s string) error {
+    var err error
+    switch n := len(s); {
+    case n < 32:
+        err = e.w.WriteByte(0xa0 | byte(n))
+    case n <= math.MaxUint8:
+        e.buf[0], e.buf[1] = 0xd9, byte(n)
+        err = e.write(2)
+    case n <= math.MaxUint16:
+        e.buf[0] = 0xda
+        binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+        err = e.write(3)
+    default:
+        e.buf[0] = 0xdb
+        binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+        err = e.write(5)
+    }
+    if err != nil {
+        return err
+    }
+    _, err = e.w.WriteString(s)
+    return err
+}
+
+func (e *msgpackEncoder) writeBinary(b []byte) error {
+    var err error
+    switch n := len(b); {
+    case n <= math.MaxUint8:
+        e.buf[0], e.buf[1] = 0xc4, byte(n)
+        err = e.write(2)
+    case n <= math.MaxUint16:
+        e.buf[0] = 0xc5
+        binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+        err = e.write(3)
+    default:
+        e.buf[0] = 0xc6
+        binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+        err = e.write(5)
+    }
+    if err != nil {
+        return err
+    }
+    _, err = e.w.Write(b)
+    return err
+}
+
+// writeHeader writes an array or map header: a fix type for small sizes, else a 16 or 32 bit length
+func (e *msgpackEncoder) writeHeader(n int, fix byte, fixLimit int, code16, code32 byte) error {
+    switch {
+    case n < fixLimit:
+        return e.w.WriteByte(fix | byte(n))
+    case n <= math.MaxUint16:
+        e.buf[0] = code16
+        binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+        return e.write(3)
+    }
+    e.buf[0] = code32
+    binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+    return e.write(5)
+}
+
+// maxMsgpackDepth limits nesting so that hostile input cannot exhaust the stack
+const maxMsgpackDepth = 1000
+
+type msgpackDecoder struct {
+    r     *bufio.Reader
+    depth int
+}
+
+// decode reads one value into its generic Go form: nil, bool, int64, uint64, float64,
+// string, []byte, []interface{} or map[string]interface{}
+func (d *msgpackDecoder) decode() (interface{}, error) {
+    code, err := d.r.ReadByte()
+    if err != nil {
+        return nil, err
+    }
+
+    switch {
+    case code <= 0x7f:
+        return int64(code), nil
+    case code >= 0xe0:
+        return int64(int8(code)), nil
+    case code&0xe0 == 0xa0:
+        return d.readString(int(code & 0x1f))
+    case code&0xf0 == 0x90:
+        return d.readArray(int(code & 0x0f))
+    case code&0xf0 == 0x80:
+        return d.readMap(int(code & 0x0f))
+    }
+
+    switch code {
+    case 0xc0:
+        return nil, nil
+    case 0xc2:
+        return false, nil
+    case 0xc3:
+        return true, nil
+    case 0xc4, 0xc5, 0xc6:
+        n, err := d.readLength(code - 0xc4)
+        if err != nil {
+            return nil, err
+        }
+        return d.readBytes(n)
+    case 0xca:
+        b, err := d.readBytes(4)
+        if err != nil {
+            return nil, err
+        }
+        return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+    case 0xcb:
+        b, err := d.readBytes(8)
+        if err != nil {
+            return nil, err
+        }
+        return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+    case 0xcc, 0xcd, 0xce, 0xcf:
+        b, err := d.readBytes(1 << (code - 0xcc))
+        if err != nil {
+            return nil, err
+        }
+        return readUint(b), nil
+    case 0xd0, 0xd1, 0xd2, 0xd3:
+        b, err := d.readBytes(1 << (code - 0xd0))
+        if err != nil {
+            return nil, err
+        }
+        u := readUint(b)
+        // sign extend from the encoded width
+        shift := 64 - 8*uint(len(b))
+        return int64(u<<shift) >> shift, nil
+    case 0xd9, 0xda, 0xdb:
+        n, err := d.readLength(code - 0xd9)
+        if err != nil {
+            return nil, err
+        }
+        return d.readString(n)
+    case 0xdc, 0xdd:
+        n, err := d.readLength(code - 0xdc + 1)
+        if err != nil {
+            return nil, err
+        }
+        return d.readArray(n)
+    case 0xde, 0xdf:
+        n, err := d.readLength(code - 0xde + 1)
+        if err != nil {
+            return nil, err
+        }
+        return d.readMap(n)
+    }
+    return nil, fmt.Errorf("msgpack: unsupported type code 0x%02x", code)
+}
+
+// readLength reads a big endian length of 1, 2 or 4 bytes for width 0, 1 or 2
+func (d *msgpackDecoder) readLength(width byte) (int, error) {
+    b, err := d.readBytes(1 << width)
+    if err != nil {
+        return 0, err
+    }
+    return int(readUint(b)), nil
+}
+
+func readUint(b []byte) uint64 {
+    var n uint64
+    for _, c := range b {
+        n = n<<8 | uint64(c)
+    }
+    return n
+}
+
+func (d *msgpackDecoder) readBytes(n int) ([]byte, error) {
+    // read in chunks so that a forged length cannot force a huge allocation up front
+    const chunk = 64 * 1024
+    b := make([]byte, 0, minInt(n, chunk))
+    for len(b) < n {
+        next := minInt(n-len(b), chunk)
+        start := len(b)
+        b = append(b, make([]byte, next)...)
+        if _, err := io.ReadFull(d.r, b[start:]); err != nil {
+            return nil, unexpectedEOF(err)
+        }
+    }
+    return b, nil
+}
+
+func (d *msgpackDecoder) readString(n int) (string, error) {
+    b, err := d.readBytes(n)
+    return string(b), err
+}
+
+func (d *msgpackDecoder) readArray(n int) (interface{}, error) {
+    if d.depth++; d.depth > maxMsgpackDepth {
+        return nil, errors.New("msgpack: maximum nesting depth exceeded")
+    }
+    defer func() { d.depth-- }()
+
+    arr := make([]interface{}, 0, minInt(n, 1024))
+    for i := 0; i < n; i++ {
+        v, err := d.decode()
+        if err != nil {
+            return nil, unexpectedEOF(err)
+        }
+        arr = append(arr, v)
+    }
+    return arr, nil
+}
+
+func (d *msgpackDecoder) readMap(n int) (interface{}, error) {
+    if d.depth++; d.depth > maxMsgpackDepth {
+        return nil, errors.New("msgpack: maximum nesting depth exceeded")
+    }
+    defer func() { d.depth-- }()
+
+    m := make(map[string]interface{}, minInt(n, 1024))
+    for i := 0; i < n; i++ {
+        k, err := d.decode()
+        if err != nil {
+            return nil, unexpectedEOF(err)
+        }
+        v, err := d.decode()
+        if err != nil {
+            return nil, unexpectedEOF(err)
+        }
+        key, ok := k.(string)
+        if !ok {
+            key = fmt.Sprint(k)
+        }
+        m[key] = v
+    }
+    return m, nil
+}
+
+var (
+    textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+    jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// setMsgpackValue stores x, a value in the generic form returned by msgpackDecoder.decode, in v
+func setMsgpackValue(v reflect.Value, x interface{}) error {
+    if x == nil {
+        switch v.Kind() {
+        case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+            v.Set(reflect.Zero(v.Type()))
+        }
+        return nil
+    }
+
+    if v.Kind() != reflect.Pointer && v.CanAddr() {
+        target := v.Addr()
+        if s, ok := x.(string); ok && target.Type().Implements(textUnmarshalerType) {
+            return target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+        }
+        _, isBinary := x.([]byte)
+        byteSlice := v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8
+        // binary data of a byte slice such as json.RawMessage is stored as is, as it was encoded
+        if target.Type().Implements(jsonUnmarshalerType) && !(isBinary && byteSlice) {
+            data, err := json.Marshal(x)
+            if err != nil {
+                return err
+            }
+            return target.Interface().(json.Unmarshaler).UnmarshalJSON(data)
+        }
+    }
+
+    mismatch := fmt.Errorf("msgpack: cannot decode %T into %s", x, v.Type())
+    switch v.Kind() {
+    case reflect.Pointer:
+        if v.IsNil() {
+            v.Set(reflect.New(v.Type().Elem()))
+        }
+        return setMsgpackValue(v.Elem(), x)
+    case reflect.Interface:
+        if v.NumMethod() > 0 {
+            return mismatch
+        }
+        v.Set(reflect.ValueOf(x))
+    case reflect.Bool:
+        b, ok := x.(bool)
+        if !ok {
+            return mismatch
+        }
+        v.SetBool(b)
+    case reflect.String:
+        switch s := x.(type) {
+        case string:
+            v.SetString(s)
+        case []byte:
+            v.SetString(string(s))
+        default:
+            return mismatch
+        }
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        var n int64
+        switch number := x.(type) {
+        case int64:
+            n = number
+        case uint64:
+            if number > math.MaxInt64 {
+                return mismatch
+            }
+            n = int64(number)
+        case float64:
+            if number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+                return mismatch
+            }
+            n = int64(number)
+        default:
+            return mismatch
+        }
+        if v.OverflowInt(n) {
+            return mismatch
+        }
+        v.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        var n uint64
+        switch number := x.(type) {
+        case uint64:
+            n = number
+        case int64:
+            if number < 0 {
+                return mismatch
+            }
+            n = uint64(number)
+        case float64:
+            if number != math.Trunc(number) || number < 0 || number >= math.MaxUint64 {
+                return mismatch
+            }
+            n = uint64(number)
+        default:
+            return mismatch
+        }
+        if v.OverflowUint(n) {
+            return mismatch
+        }
+        v.SetUint(n)
+    case reflect.Float32, reflect.Float64:
+        switch number := x.(type) {
+        case float64:
+            v.SetFloat(number)
+        case int64:
+            v.SetFloat(float64(number))
+        case uint64:
+            v.SetFloat(float64(number))
+        default:
+            return mismatch
+        }
+    case reflect.Slice:
+        if v.Type().Elem().Kind() == reflect.Uint8 {
+            switch b := x.(type) {
+            case []byte:
+                v.SetBytes(append([]byte(nil), b...))
+                return nil
+            case string:
+                v.SetBytes([]byte(b))
+                return nil
+            }
+        }
+        items, ok := x.([]interface{})
+        if !ok {
+            return mismatch
+        }
+        slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+        for i, item := range items {
+            if err := setMsgpackValue(slice.Index(i), item); err != nil {
+                return err
+            }
+        }
+        v.Set(slice)
+    case reflect.Array:
+        items, ok := x.([]interface{})
+        if b, isBytes := x.([]byte); isBytes && v.Type().Elem().Kind() == reflect.Uint8 {
+            v.Set(reflect.Zero(v.Type()))
+            for i := 0; i < len(b) && i < v.Len(); i++ {
+                v.Index(i).SetUint(uint64(b[i]))
+            }
+            return nil
+        }
+        if !ok {
+            return mismatch
+        }
+        v.Set(reflect.Zero(v.Type()))
+        for i := 0; i < len(items) && i < v.Len(); i++ {
+            if err := setMsgpackValue(v.Index(i), items[i]); err != nil {
+                return err
+            }
+        }
+    case reflect.Map:
+        m, ok := x.(map[string]interface{})
+        if !ok {
+            return mismatch
+        }
+        if v.IsNil() {
+            v.Set(reflect.MakeMapWithSize(v.Type(), len(m)))
+        }
+        for k, item := range m {
+            key := reflect.New(v.Type().Key()).Elem()
+            if err := setMsgpackMapKey(key, k); err != nil {
+                return err
+            }
+            elem := reflect.New(v.Type().Elem()).Elem()
+            if err := setMsgpackValue(elem, item); err != nil {
+                return err
+            }
+            v.SetMapIndex(key, elem)
+        }
+    case reflect.Struct:
+        m, ok := x.(map[string]interface{})
+        if !ok {
+            return mismatch
+        }
+        fields := msgpackStructFields(v.Type(), nil)
+        for k, item := range m {
+            f, found := findMsgpackField(fields, k)
+            if !found {
+                continue
+            }
+            if err := setMsgpackValue(v.FieldByIndex(f.index), item); err != nil {
+                return fmt.Errorf("msgpack: field %q: %w", k, err)
+            }
+        }
+    default:
+        return mismatch
+    }
+    return nil
+}
+
+// setMsgpackMapKey stores the map key k in key, which has a string or integer kind
+func setMsgpackMapKey(key reflect.Value, k string) error {
+    switch key.Kind() {
+    case reflect.String:
+        key.SetString(k)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := strconv.ParseInt(k, 10, key.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("msgpack: invalid map key %q: %w", k, err)
+        }
+        key.SetInt(n)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        n, err := strconv.ParseUint(k, 10, key.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("msgpack: invalid map key %q: %w", k, err)
+        }
+        key.SetUint(n)
+    default:
+        return fmt.Errorf("msgpack: unsupported map key type %s", key.Type())
+    }
+    return nil
+}
+
+// findMsgpackField returns the field named name, preferring an exact match
+func findMsgpackField(fields []msgpackStructField, name string) (msgpackStructField, bool) {
+    for _, f := range fields {
+        if f.name == name {
+            return f, true
+        }
+    }
+    for _, f := range fields {
+        if strings.EqualFold(f.name, name) {
+            return f, true
+        }
+    }
+    return msgpackStructField{}, false
+}
+
+// unexpectedEOF turns io.EOF in the middle of a value into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+    if errors.Is(err, io.EOF) {
+        return io.ErrUnexpectedEOF
+    }
+    return err
+}
+
+func minInt(a, b int) int {
+    if a < b {
+        return a
+    }
+    return b
+}
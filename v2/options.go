@@ -0,0 +1,182 @@
+package toolkit
+
+import (
+    "compress/gzip"
+    "fmt"
+    "log/slog"
+    "strings"
+)
+
+// ConfigError is returned by New when the configuration is invalid
+type ConfigError struct {
+    // Problems describes every invalid setting
+    Problems []string
+}
+
+func (e *ConfigError) Error() string {
+    return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Option configures the Tools returned by New
+type Option func(*Tools)
+
+// New returns Tools configured by opts, with defaults for the sizes left unset. It fails with
+// an error listing every invalid setting
+func New(opts ...Option) (*Tools, error) {
+    t := &Tools{}
+    for _, opt := range opts {
+        opt(t)
+    }
+
+    if t.MaxFileSize == 0 {
+        t.MaxFileSize = DefaultMaxFileSize
+    }
+    if t.MaxJSONSize == 0 {
+        t.MaxJSONSize = DefaultMaxJSONSize
+    }
+    if t.MaxJSONElementSize == 0 {
+        t.MaxJSONElementSize = t.maxJSONElementSize()
+    }
+
+    if err := t.checkConfig(); err != nil {
+        return nil, err
+    }
+    return t, nil
+}
+
+// WithMaxFileSize limits the size of uploads
+func WithMaxFileSize(n int) Option {
+    return func(t *Tools) { t.MaxFileSize = n }
+}
+
+// WithAllowedFileTypes restricts uploads to the given MIME types
+func WithAllowedFileTypes(types ...string) Option {
+    return func(t *Tools) { t.AllowedFileTypes = append([]string(nil), types...) }
+}
+
+// WithMaxJSONSize limits the size of the request bodies read
+func WithMaxJSONSize(n int) Option {
+    return func(t *Tools) { t.MaxJSONSize = n }
+}
+
+// WithMaxJSONElementSize limits the size of the elements read by ReadJSONStream
+func WithMaxJSONElementSize(n int) Option {
+    return func(t *Tools) { t.MaxJSONElementSize = n }
+}
+
+// WithUnknownJSONFields accepts JSON objects with fields missing from the destination
+func WithUnknownJSONFields() Option {
+    return func(t *Tools) { t.AllowUnknownFields = true }
+}
+
+// WithJSONValidation validates decoded JSON with the validate struct tags
+func WithJSONValidation() Option {
+    return func(t *Tools) { t.ValidateJSON = true }
+}
+
+// WithCodecs replaces the default codecs of ReadBody and WriteBody
+func WithCodecs(codecs *CodecRegistry) Option {
+    return func(t *Tools) { t.Codecs = codecs }
+}
+
+// WithErrorFormat selects the body written by ErrorJSON
+func WithErrorFormat(format ErrorFormat) Option {
+    return func(t *Tools) { t.ErrorFormat = format }
+}
+
+// WithErrorRenderer replaces the body written by ErrorJSON
+func WithErrorRenderer(renderer ErrorRenderer) Option {
+    return func(t *Tools) { t.ErrorRenderer = renderer }
+}
+
+// WithErrorRegistry maps errors to status codes and messages in ErrorJSON
+func WithErrorRegistry(registry *ErrorRegistry) Option {
+    return func(t *Tools) { t.Errors = registry }
+}
+
+// WithLogger replaces slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+    return func(t *Tools) { t.Logger = logger }
+}
+
+// WithServerErrorRedaction hides server errors from clients behind a correlation ID, read from
+// and written to header, DefaultCorrelationIDHeader if empty
+func WithServerErrorRedaction(header string) Option {
+    return func(t *Tools) {
+        t.RedactServerErrors = true
+        t.CorrelationIDHeader = header
+    }
+}
+
+// WithRetry retries failed calls to remote services according to policy
+func WithRetry(policy RetryPolicy) Option {
+    return func(t *Tools) { t.Retry = &policy }
+}
+
+// WithCircuitBreaker stops calling remote hosts which keep failing. The breaker may be shared
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+    return func(t *Tools) { t.CircuitBreaker = breaker }
+}
+
+// WithAuthenticator adds credentials to calls to remote services
+func WithAuthenticator(authenticator Authenticator) Option {
+    return func(t *Tools) { t.Authenticator = authenticator }
+}
+
+// WithCompression gzips large request bodies sent to remote services
+func WithCompression(compression Compression) Option {
+    return func(t *Tools) { t.Compression = &compression }
+}
+
+// WithPasswordPolicy configures password hashing and strength checks
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+    return func(t *Tools) { t.Passwords = &policy }
+}
+
+// checkConfig returns an error listing every invalid setting, or nil
+func (t *Tools) checkConfig() error {
+    var problems []string
+    check := func(ok bool, format string, args ...interface{}) {
+        if !ok {
+            problems = append(problems, fmt.Sprintf(format, args...))
+        }
+    }
+
+    check(t.MaxFileSize >= 0, "MaxFileSize must not be negative")
+    check(t.MaxJSONSize >= 0, "MaxJSONSize must not be negative")
+    check(t.MaxJSONElementSize >= 0, "MaxJSONElementSize must not be negative")
+    check(t.maxJSONElementSize() <= t.maxJSONSize(), "MaxJSONElementSize must not exceed MaxJSONSize")
+    for _, fileType := range t.AllowedFileTypes {
+        check(strings.Contains(fileType, "/"), "AllowedFileTypes: %q is not a MIME type", fileType)
+    }
+    check(t.ErrorFormat == ErrorFormatLegacy || t.ErrorFormat == ErrorFormatProblem, "unknown ErrorFormat %d", t.ErrorFormat)
+
+    if p := t.Retry; p != nil {
+        check(p.MaxAttempts >= 0, "Retry.MaxAttempts must not be negative")
+        check(p.InitialBackoff >= 0, "Retry.InitialBackoff must not be negative")
+        check(p.MaxBackoff >= 0, "Retry.MaxBackoff must not be negative")
+        check(p.InitialBackoff <= p.maxBackoff(), "Retry.InitialBackoff must not exceed MaxBackoff")
+        check(p.Multiplier == 0 || p.Multiplier >= 1, "Retry.Multiplier must be at least 1")
+        check(p.Jitter >= 0 && p.Jitter <= 1, "Retry.Jitter must be between 0 and 1")
+    }
+    if b := t.CircuitBreaker; b != nil {
+        check(b.FailureThreshold >= 0, "CircuitBreaker.FailureThreshold must not be negative")
+        check(b.OpenTimeout >= 0, "CircuitBreaker.OpenTimeout must not be negative")
+    }
+    if c := t.Compression; c != nil {
+        check(c.MinSize >= 0, "Compression.MinSize must not be negative")
+        check(c.Level >= gzip.HuffmanOnly && c.Level <= gzip.BestCompression, "Compression.Level must be between %d and %d", gzip.HuffmanOnly, gzip.BestCompression)
+    }
+    if p := t.Passwords; p != nil {
+        check(p.Iterations >= 0, "Passwords.Iterations must not be negative")
+        check(p.SaltLength == 0 || p.SaltLength >= 8, "Passwords.SaltLength must be at least 8")
+        check(p.KeyLength == 0 || p.KeyLength >= 16, "Passwords.KeyLength must be at least 16")
+        check(p.MinLength >= 0, "Passwords.MinLength must not be negative")
+        check(p.MinEntropy >= 0, "Passwords.MinEntropy must not be negative")
+    }
+
+    if len(problems) == 0 {
+        return nil
+    }
+    return &ConfigError{Problems: problems}
+}
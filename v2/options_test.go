@@ -0,0 +1,122 @@
+package toolkit
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestNew(t *testing.T) {
+    tools, err := New()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tools.MaxFileSize != DefaultMaxFileSize || tools.MaxJSONSize != DefaultMaxJSONSize || tools.MaxJSONElementSize != DefaultMaxJSONElementSize {
+        t.Errorf("defaults not applied: %+v", tools)
+    }
+
+    retry := RetryPolicy{MaxAttempts: 5}
+    types := []string{"image/png"}
+    tools, err = New(
+        WithMaxFileSize(10*1024*1024),
+        WithAllowedFileTypes(types...),
+        WithMaxJSONSize(2048),
+        WithMaxJSONElementSize(1024),
+        WithUnknownJSONFields(),
+        WithJSONValidation(),
+        WithErrorFormat(ErrorFormatProblem),
+        WithServerErrorRedaction("X-Request-ID"),
+        WithRetry(retry),
+        WithCircuitBreaker(NewCircuitBreaker(3, time.Second)),
+        WithAuthenticator(BearerToken("token")),
+        WithCompression(Compression{MinSize: 4096}),
+        WithPasswordPolicy(PasswordPolicy{Iterations: 700000}),
+    )
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tools.MaxFileSize != 10*1024*1024 || tools.MaxJSONSize != 2048 || !tools.AllowUnknownFields || !tools.ValidateJSON ||
+        tools.ErrorFormat != ErrorFormatProblem || !tools.RedactServerErrors || tools.CorrelationIDHeader != "X-Request-ID" ||
+        tools.Retry.MaxAttempts != 5 || tools.CircuitBreaker == nil || tools.Authenticator == nil ||
+        tools.Compression.MinSize != 4096 || tools.Passwords.Iterations != 700000 {
+        t.Errorf("options not applied: %+v", tools)
+    }
+
+    // the options keep copies of their arguments
+    retry.MaxAttempts, types[0] = 1, "text/html"
+    if tools.Retry.MaxAttempts != 5 || tools.AllowedFileTypes[0] != "image/png" {
+        t.Error("configuration shares memory with the caller")
+    }
+}
+
+func TestNew_SmallJSONSize(t *testing.T) {
+    // the element limit follows a body limit below its default
+    tools, err := New(WithMaxJSONSize(64 * 1024))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tools.MaxJSONElementSize != 64*1024 {
+        t.Errorf("expected an element limit of 64 KiB, got %d", tools.MaxJSONElementSize)
+    }
+
+    tools, err = New(WithConfig(Config{MaxJSONSize: 2048}))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tools.MaxJSONElementSize != 2048 {
+        t.Errorf("expected an element limit of 2048, got %d", tools.MaxJSONElementSize)
+    }
+}
+
+func TestNew_Invalid(t *testing.T) {
+    _, err := New(
+        WithMaxFileSize(-1),
+        WithMaxJSONSize(1024),
+        WithMaxJSONElementSize(2048),
+        WithAllowedFileTypes("png"),
+        WithErrorFormat(42),
+        WithRetry(RetryPolicy{Jitter: 2, InitialBackoff: time.Minute}),
+        WithCompression(Compression{Level: 12}),
+        WithPasswordPolicy(PasswordPolicy{SaltLength: 4}),
+    )
+
+    var configError *ConfigError
+    if !errors.As(err, &configError) {
+        t.Fatalf("expected a *ConfigError, got %v", err)
+    }
+    for _, expected := range []string{
+        "MaxFileSize", "MaxJSONElementSize must not exceed", "AllowedFileTypes", "ErrorFormat",
+        "Retry.Jitter", "Retry.InitialBackoff", "Compression.Level", "Passwords.SaltLength",
+    } {
+        if !strings.Contains(err.Error(), expected) {
+            t.Errorf("%s not reported in %q", expected, err.Error())
+        }
+    }
+    if len(configError.Problems) != 8 {
+        t.Errorf("expected 8 problems, got %v", configError.Problems)
+    }
+}
+
+func TestTools_UploadFiles_NoMutation(t *testing.T) {
+    var testTools Tools
+
+    // concurrent uploads with a zero value Tools must not race, which -race reports
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            request, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+            request.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+            _, _ = testTools.UploadFiles(request, t.TempDir())
+        }()
+    }
+    wg.Wait()
+
+    if testTools.MaxFileSize != 0 {
+        t.Errorf("UploadFiles changed MaxFileSize to %d", testTools.MaxFileSize)
+    }
+}
@@ -0,0 +1,253 @@
+package toolkit
+
+import (
+    "crypto/hmac"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// OTPAlgorithm is the HMAC hash function of HOTP and TOTP codes
+type OTPAlgorithm int
+
+const (
+    // OTPAlgorithmSHA1 is the default of RFC 4226, and the only one supported by some authenticator apps
+    OTPAlgorithmSHA1 OTPAlgorithm = iota
+    OTPAlgorithmSHA256
+    OTPAlgorithmSHA512
+)
+
+// String returns the name of a in otpauth URIs
+func (a OTPAlgorithm) String() string {
+    switch a {
+    case OTPAlgorithmSHA256:
+        return "SHA256"
+    case OTPAlgorithmSHA512:
+        return "SHA512"
+    }
+    return "SHA1"
+}
+
+func (a OTPAlgorithm) hash() (func() hash.Hash, error) {
+    switch a {
+    case OTPAlgorithmSHA1:
+        return sha1.New, nil
+    case OTPAlgorithmSHA256:
+        return sha256.New, nil
+    case OTPAlgorithmSHA512:
+        return sha512.New, nil
+    }
+    return nil, fmt.Errorf("unknown otp algorithm %d", a)
+}
+
+// otpSecretEncoding is the unpadded base32 encoding used for secrets by authenticator apps
+var otpSecretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewOTPSecret returns a random secret of size bytes, 20 if zero as recommended by RFC 4226
+func (t *Tools) NewOTPSecret(size int) ([]byte, error) {
+    if size == 0 {
+        size = 20
+    }
+    if size < 16 {
+        return nil, fmt.Errorf("otp secrets must have at least 16 bytes, not %d", size)
+    }
+    secret := make([]byte, size)
+    if _, err := io.ReadFull(randReader, secret); err != nil {
+        return nil, fmt.Errorf("error reading random bytes: %w", err)
+    }
+    return secret, nil
+}
+
+// EncodeOTPSecret returns secret in unpadded base32, the form shown to users and used in otpauth URIs
+func EncodeOTPSecret(secret []byte) string {
+    return otpSecretEncoding.EncodeToString(secret)
+}
+
+// DecodeOTPSecret decodes a base32 secret. Case, spaces, hyphens and padding are ignored,
+// as users often type secrets by hand
+func DecodeOTPSecret(s string) ([]byte, error) {
+    s = strings.Map(func(r rune) rune {
+        if r == ' ' || r == '-' || r == '=' {
+            return -1
+        }
+        return r
+    }, strings.ToUpper(s))
+
+    secret, err := otpSecretEncoding.DecodeString(s)
+    if err != nil {
+        return nil, fmt.Errorf("malformed otp secret: %w", err)
+    }
+    return secret, nil
+}
+
+// OTP generates and verifies HOTP (RFC 4226) and TOTP (RFC 6238) one-time passwords
+type OTP struct {
+    Secret    []byte
+    Algorithm OTPAlgorithm
+    // Digits is the length of codes, between 6 and 10; 6 if zero
+    Digits int
+    // Period is the lifetime of a TOTP code, in whole seconds; 30s if zero
+    Period time.Duration
+    // Skew is the number of codes accepted on either side of the expected one: time steps
+    // before and after the current one for TOTP, counters ahead of the current one for HOTP
+    Skew int
+    // Issuer and AccountName label the account in authenticator apps
+    Issuer      string
+    AccountName string
+
+    now func() time.Time
+}
+
+func (o *OTP) digits() int {
+    if o.Digits == 0 {
+        return 6
+    }
+    return o.Digits
+}
+
+func (o *OTP) period() time.Duration {
+    if o.Period < time.Second {
+        return 30 * time.Second
+    }
+    return o.Period
+}
+
+func (o *OTP) clock() time.Time {
+    if o.now != nil {
+        return o.now()
+    }
+    return time.Now()
+}
+
+// HOTP returns the code for counter
+func (o *OTP) HOTP(counter uint64) (string, error) {
+    if len(o.Secret) == 0 {
+        return "", errors.New("otp has no secret")
+    }
+    digits := o.digits()
+    if digits < 6 || digits > 10 {
+        return "", fmt.Errorf("otp codes must have between 6 and 10 digits, not %d", digits)
+    }
+    newHash, err := o.Algorithm.hash()
+    if err != nil {
+        return "", err
+    }
+
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], counter)
+    mac := hmac.New(newHash, o.Secret)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    // dynamic truncation, RFC 4226 section 5.3
+    offset := sum[len(sum)-1] & 0x0f
+    value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+
+    code := strconv.FormatUint(value%pow10(digits), 10)
+    return strings.Repeat("0", digits-len(code)) + code, nil
+}
+
+// VerifyHOTP checks code against counter and the Skew following counters. On success it
+// returns the counter to store for the next verification, one past the matching counter
+func (o *OTP) VerifyHOTP(code string, counter uint64) (uint64, bool) {
+    for i := 0; i <= o.Skew; i++ {
+        if o.matches(code, counter+uint64(i)) {
+            return counter + uint64(i) + 1, true
+        }
+    }
+    return counter, false
+}
+
+// TOTP returns the current code
+func (o *OTP) TOTP() (string, error) {
+    return o.TOTPAt(o.clock())
+}
+
+// TOTPAt returns the code valid at t
+func (o *OTP) TOTPAt(t time.Time) (string, error) {
+    return o.HOTP(o.step(t))
+}
+
+// step returns the TOTP time step of t
+func (o *OTP) step(t time.Time) uint64 {
+    return uint64(t.Unix() / int64(o.period()/time.Second))
+}
+
+// VerifyTOTP checks code against the current time step and the Skew steps around it. On
+// success it returns the matching time step; store it and refuse codes with a step at or
+// before it, so that a code cannot be used twice
+func (o *OTP) VerifyTOTP(code string) (uint64, bool) {
+    current := o.step(o.clock())
+    for i := -o.Skew; i <= o.Skew; i++ {
+        if i < 0 && uint64(-i) > current {
+            continue
+        }
+        step := current + uint64(i)
+        if o.matches(code, step) {
+            return step, true
+        }
+    }
+    return 0, false
+}
+
+// matches compares code with the code for counter in constant time
+func (o *OTP) matches(code string, counter uint64) bool {
+    expected, err := o.HOTP(counter)
+    if err != nil {
+        return false
+    }
+    return subtle.ConstantTimeCompare([]byte(code), []byte(expected)) == 1
+}
+
+// TOTPURI returns the otpauth:// provisioning URI of a TOTP account, usually shown as a QR code
+func (o *OTP) TOTPURI() string {
+    params := o.uriParams()
+    params.Set("period", strconv.Itoa(int(o.period()/time.Second)))
+    return o.uri("totp", params)
+}
+
+// HOTPURI returns the otpauth:// provisioning URI of an HOTP account starting at counter
+func (o *OTP) HOTPURI(counter uint64) string {
+    params := o.uriParams()
+    params.Set("counter", strconv.FormatUint(counter, 10))
+    return o.uri("hotp", params)
+}
+
+func (o *OTP) uriParams() url.Values {
+    params := url.Values{}
+    params.Set("secret", EncodeOTPSecret(o.Secret))
+    if o.Issuer != "" {
+        params.Set("issuer", o.Issuer)
+    }
+    params.Set("algorithm", o.Algorithm.String())
+    params.Set("digits", strconv.Itoa(o.digits()))
+    return params
+}
+
+func (o *OTP) uri(kind string, params url.Values) string {
+    label := o.AccountName
+    if o.Issuer != "" {
+        label = o.Issuer + ":" + label
+    }
+    u := url.URL{Scheme: "otpauth", Host: kind, Path: "/" + label, RawQuery: params.Encode()}
+    return u.String()
+}
+
+func pow10(n int) uint64 {
+    p := uint64(1)
+    for i := 0; i < n; i++ {
+        p *= 10
+    }
+    return p
+}
@@ -0,0 +1,207 @@
+package toolkit
+
+import (
+    "bytes"
+    "crypto/rand"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestOTP_HOTP(t *testing.T) {
+    // RFC 4226 appendix D
+    expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+    otp := &OTP{Secret: []byte("12345678901234567890")}
+
+    for counter, want := range expected {
+        code, err := otp.HOTP(uint64(counter))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if code != want {
+            t.Errorf("counter %d: expected %s, got %s", counter, want, code)
+        }
+    }
+}
+
+var totpTests = []struct {
+    unix   int64
+    sha1   string
+    sha256 string
+    sha512 string
+}{
+    // RFC 6238 appendix B
+    {unix: 59, sha1: "94287082", sha256: "46119246", sha512: "90693936"},
+    {unix: 1111111109, sha1: "07081804", sha256: "68084774", sha512: "25091201"},
+    {unix: 1111111111, sha1: "14050471", sha256: "67062674", sha512: "99943326"},
+    {unix: 1234567890, sha1: "89005924", sha256: "91819424", sha512: "93441116"},
+    {unix: 2000000000, sha1: "69279037", sha256: "90698825", sha512: "38618901"},
+    {unix: 20000000000, sha1: "65353130", sha256: "77737706", sha512: "47863826"},
+}
+
+func TestOTP_TOTPAt(t *testing.T) {
+    otps := map[OTPAlgorithm]*OTP{
+        OTPAlgorithmSHA1:   {Secret: []byte("12345678901234567890"), Algorithm: OTPAlgorithmSHA1, Digits: 8},
+        OTPAlgorithmSHA256: {Secret: []byte("12345678901234567890123456789012"), Algorithm: OTPAlgorithmSHA256, Digits: 8},
+        OTPAlgorithmSHA512: {Secret: []byte("1234567890123456789012345678901234567890123456789012345678901234"), Algorithm: OTPAlgorithmSHA512, Digits: 8},
+    }
+
+    for _, test := range totpTests {
+        for algorithm, want := range map[OTPAlgorithm]string{
+            OTPAlgorithmSHA1:   test.sha1,
+            OTPAlgorithmSHA256: test.sha256,
+            OTPAlgorithmSHA512: test.sha512,
+        } {
+            code, err := otps[algorithm].TOTPAt(time.Unix(test.unix, 0))
+            if err != nil {
+                t.Fatal(err)
+            }
+            if code != want {
+                t.Errorf("%s at %d: expected %s, got %s", algorithm, test.unix, want, code)
+            }
+        }
+    }
+}
+
+func TestOTP_VerifyTOTP(t *testing.T) {
+    now := time.Unix(1700000000, 0)
+    otp := &OTP{Secret: []byte("12345678901234567890"), Skew: 1, now: func() time.Time { return now }}
+
+    var tests = []struct {
+        name   string
+        offset time.Duration
+        valid  bool
+    }{
+        {name: "current", offset: 0, valid: true},
+        {name: "previous step", offset: -30 * time.Second, valid: true},
+        {name: "next step", offset: 30 * time.Second, valid: true},
+        {name: "too old", offset: -60 * time.Second, valid: false},
+        {name: "too new", offset: 60 * time.Second, valid: false},
+    }
+    for _, test := range tests {
+        code, _ := otp.TOTPAt(now.Add(test.offset))
+        step, ok := otp.VerifyTOTP(code)
+        if ok != test.valid {
+            t.Errorf("%s: expected valid %v, got %v", test.name, test.valid, ok)
+        }
+        if ok && step != otp.step(now.Add(test.offset)) {
+            t.Errorf("%s: unexpected step %d", test.name, step)
+        }
+    }
+
+    if _, ok := otp.VerifyTOTP("12345"); ok {
+        t.Error("short code accepted")
+    }
+}
+
+func TestOTP_VerifyHOTP(t *testing.T) {
+    otp := &OTP{Secret: []byte("12345678901234567890"), Skew: 2}
+
+    // the user pressed the button twice without logging in
+    code, _ := otp.HOTP(7)
+    next, ok := otp.VerifyHOTP(code, 5)
+    if !ok || next != 8 {
+        t.Errorf("expected counter 8, got %d %v", next, ok)
+    }
+
+    code, _ = otp.HOTP(8)
+    if next, ok = otp.VerifyHOTP(code, 5); ok || next != 5 {
+        t.Errorf("code beyond the window accepted, counter %d", next)
+    }
+    code, _ = otp.HOTP(4)
+    if _, ok = otp.VerifyHOTP(code, 5); ok {
+        t.Error("used code accepted")
+    }
+}
+
+func TestOTP_Errors(t *testing.T) {
+    var tests = []struct {
+        name string
+        otp  *OTP
+    }{
+        {name: "no secret", otp: &OTP{}},
+        {name: "too few digits", otp: &OTP{Secret: []byte("secret"), Digits: 5}},
+        {name: "too many digits", otp: &OTP{Secret: []byte("secret"), Digits: 11}},
+        {name: "unknown algorithm", otp: &OTP{Secret: []byte("secret"), Algorithm: 42}},
+    }
+    for _, test := range tests {
+        if _, err := test.otp.HOTP(0); err == nil {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+    }
+}
+
+func TestTools_NewOTPSecret(t *testing.T) {
+    var testTools Tools
+
+    secret, err := testTools.NewOTPSecret(0)
+    if err != nil || len(secret) != 20 {
+        t.Fatalf("unexpected secret %v: %v", secret, err)
+    }
+    if _, err = testTools.NewOTPSecret(8); err == nil {
+        t.Error("short secret: error expected, but none received")
+    }
+
+    encoded := EncodeOTPSecret(secret)
+    if strings.Contains(encoded, "=") || len(encoded) != 32 {
+        t.Errorf("unexpected encoding %s", encoded)
+    }
+
+    // as typed by a user
+    typed := strings.ToLower(encoded[:4] + " " + encoded[4:8] + "-" + encoded[8:])
+    decoded, err := DecodeOTPSecret(typed)
+    if err != nil || !bytes.Equal(decoded, secret) {
+        t.Errorf("secret does not round trip: %v", err)
+    }
+    if _, err = DecodeOTPSecret("not base32!"); err == nil {
+        t.Error("malformed secret: error expected, but none received")
+    }
+
+    randReader = failingReader{}
+    defer func() { randReader = rand.Reader }()
+    if _, err = testTools.NewOTPSecret(0); err == nil {
+        t.Error("entropy failure: error expected, but none received")
+    }
+}
+
+func TestOTP_URI(t *testing.T) {
+    otp := &OTP{
+        Secret:      []byte("12345678901234567890"),
+        Algorithm:   OTPAlgorithmSHA256,
+        Digits:      8,
+        Period:      60 * time.Second,
+        Issuer:      "ACME Co",
+        AccountName: "john@example.com",
+    }
+
+    u, err := url.Parse(otp.TOTPURI())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/ACME Co:john@example.com" {
+        t.Errorf("unexpected uri %s", u)
+    }
+    if !strings.HasPrefix(otp.TOTPURI(), "otpauth://totp/ACME%20Co:john@example.com?") {
+        t.Errorf("unexpected label in %s", otp.TOTPURI())
+    }
+
+    query := u.Query()
+    expected := map[string]string{
+        "secret":    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+        "issuer":    "ACME Co",
+        "algorithm": "SHA256",
+        "digits":    "8",
+        "period":    "60",
+    }
+    for k, v := range expected {
+        if query.Get(k) != v {
+            t.Errorf("%s: expected %q, got %q", k, v, query.Get(k))
+        }
+    }
+
+    u, _ = url.Parse(otp.HOTPURI(3))
+    if u.Host != "hotp" || u.Query().Get("counter") != "3" || u.Query().Has("period") {
+        t.Errorf("unexpected uri %s", u)
+    }
+}
@@ -0,0 +1,299 @@
+package toolkit
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// ErrInvalidPasswordHash is returned when a stored password hash cannot be parsed
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// passwordHashID is the PHC identifier of the hashes made by HashPassword
+const passwordHashID = "pbkdf2-sha256"
+
+// PasswordPolicy configures password hashing and the strength checks of CheckPasswordStrength
+type PasswordPolicy struct {
+    // Iterations is the PBKDF2 iteration count, 600000 if zero as recommended by OWASP
+    Iterations int
+    // SaltLength is the size of salts in bytes, 16 if zero
+    SaltLength int
+    // KeyLength is the size of derived keys in bytes, 32 if zero
+    KeyLength int
+    // MinLength is the minimum number of characters of a password, 8 if zero
+    MinLength int
+    // MinEntropy is the minimum estimated entropy of a password in bits, 40 if zero
+    MinEntropy float64
+    // CommonPasswords are refused in addition to a built-in list of the most common passwords
+    CommonPasswords []string
+}
+
+func (p *PasswordPolicy) iterations() int {
+    if p == nil || p.Iterations <= 0 {
+        return 600000
+    }
+    return p.Iterations
+}
+
+func (p *PasswordPolicy) saltLength() int {
+    if p == nil || p.SaltLength <= 0 {
+        return 16
+    }
+    return p.SaltLength
+}
+
+func (p *PasswordPolicy) keyLength() int {
+    if p == nil || p.KeyLength <= 0 {
+        return 32
+    }
+    return p.KeyLength
+}
+
+func (p *PasswordPolicy) minLength() int {
+    if p == nil || p.MinLength <= 0 {
+        return 8
+    }
+    return p.MinLength
+}
+
+func (p *PasswordPolicy) minEntropy() float64 {
+    if p == nil || p.MinEntropy <= 0 {
+        return 40
+    }
+    return p.MinEntropy
+}
+
+// passwordHash is a parsed PHC string
+type passwordHash struct {
+    iterations int
+    salt       []byte
+    key        []byte
+}
+
+// phcEncoding is the unpadded standard base64 of the PHC string format
+var phcEncoding = base64.RawStdEncoding
+
+// HashPassword derives a key from password with PBKDF2-HMAC-SHA256 and a random salt, using the
+// parameters of Passwords, and returns it as a PHC string:
+// $pbkdf2-sha256$i=<iterations>$<base64 salt>$<base64 key>
+func (t *Tools) HashPassword(password string) (string, error) {
+    salt := make([]byte, t.Passwords.saltLength())
+    if _, err := io.ReadFull(randReader, salt); err != nil {
+        return "", fmt.Errorf("error reading random bytes: %w", err)
+    }
+
+    iterations := t.Passwords.iterations()
+    key := pbkdf2SHA256([]byte(password), salt, iterations, t.Passwords.keyLength())
+    return fmt.Sprintf("$%s$i=%d$%s$%s", passwordHashID, iterations, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches a hash made by HashPassword, comparing the
+// keys in constant time. An error is returned only when hash is malformed
+func (t *Tools) VerifyPassword(password, hash string) (bool, error) {
+    parsed, err := parsePasswordHash(hash)
+    if err != nil {
+        return false, err
+    }
+    key := pbkdf2SHA256([]byte(password), parsed.salt, parsed.iterations, len(parsed.key))
+    return subtle.ConstantTimeCompare(key, parsed.key) == 1, nil
+}
+
+// PasswordNeedsRehash reports whether hash was made with weaker parameters than those of
+// Passwords, or cannot be parsed. Check it after a successful VerifyPassword and store a new
+// hash of the password when it returns true
+func (t *Tools) PasswordNeedsRehash(hash string) bool {
+    parsed, err := parsePasswordHash(hash)
+    if err != nil {
+        return true
+    }
+    return parsed.iterations < t.Passwords.iterations() ||
+        len(parsed.salt) < t.Passwords.saltLength() ||
+        len(parsed.key) < t.Passwords.keyLength()
+}
+
+// parsePasswordHash parses the PHC strings made by HashPassword
+func parsePasswordHash(hash string) (*passwordHash, error) {
+    parts := strings.Split(hash, "$")
+    if len(parts) != 5 || parts[0] != "" {
+        return nil, fmt.Errorf("%w: not a PHC string", ErrInvalidPasswordHash)
+    }
+    if parts[1] != passwordHashID {
+        return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidPasswordHash, parts[1])
+    }
+
+    value, ok := strings.CutPrefix(parts[2], "i=")
+    iterations, err := strconv.Atoi(value)
+    if !ok || err != nil || iterations < 1 {
+        return nil, fmt.Errorf("%w: malformed parameters %q", ErrInvalidPasswordHash, parts[2])
+    }
+
+    salt, err := phcEncoding.DecodeString(parts[3])
+    if err != nil || len(salt) == 0 {
+        return nil, fmt.Errorf("%w: malformed salt", ErrInvalidPasswordHash)
+    }
+    key, err := phcEncoding.DecodeString(parts[4])
+    if err != nil || len(key) == 0 {
+        return nil, fmt.Errorf("%w: malformed key", ErrInvalidPasswordHash)
+    }
+    return &passwordHash{iterations: iterations, salt: salt, key: key}, nil
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLength int) []byte {
+    prf := hmac.New(sha256.New, password)
+    size := prf.Size()
+    blocks := (keyLength + size - 1) / size
+
+    var index [4]byte
+    key := make([]byte, 0, blocks*size)
+    u := make([]byte, size)
+    for block := 1; block <= blocks; block++ {
+        prf.Reset()
+        prf.Write(salt)
+        binary.BigEndian.PutUint32(index[:], uint32(block))
+        prf.Write(index[:])
+        key = prf.Sum(key)
+
+        t := key[len(key)-size:]
+        copy(u, t)
+        for n := 1; n < iterations; n++ {
+            prf.Reset()
+            prf.Write(u)
+            u = prf.Sum(u[:0])
+            for i := range u {
+                t[i] ^= u[i]
+            }
+        }
+    }
+    return key[:keyLength]
+}
+
+// PasswordStrength is the result of CheckPasswordStrength
+type PasswordStrength struct {
+    // Length is the number of characters of the password
+    Length int
+    // Entropy is an estimate, in bits, of the strength of the password against guessing
+    Entropy float64
+    // Common is set when the password is in a list of common passwords
+    Common bool
+    // Problems describes why the password is refused; it is empty for an acceptable password
+    Problems []string
+}
+
+// OK reports whether the password passed all checks
+func (s PasswordStrength) OK() bool {
+    return len(s.Problems) == 0
+}
+
+// CheckPasswordStrength checks password against the MinLength, MinEntropy and common passwords
+// of Passwords. The entropy estimate assumes characters drawn at random from the character
+// classes used, and ignores characters repeating or continuing a sequence of the previous ones
+func (t *Tools) CheckPasswordStrength(password string) PasswordStrength {
+    strength := PasswordStrength{
+        Length:  len([]rune(password)),
+        Entropy: passwordEntropy(password),
+        Common:  t.commonPassword(password),
+    }
+
+    if min := t.Passwords.minLength(); strength.Length < min {
+        strength.Problems = append(strength.Problems, fmt.Sprintf("password must have at least %d characters", min))
+    }
+    if strength.Common {
+        strength.Problems = append(strength.Problems, "password is too common")
+    } else if strength.Entropy < t.Passwords.minEntropy() {
+        strength.Problems = append(strength.Problems, "password is too easy to guess")
+    }
+    return strength
+}
+
+func (t *Tools) commonPassword(password string) bool {
+    password = strings.ToLower(password)
+    if _, ok := commonPasswords[password]; ok {
+        return true
+    }
+    if t.Passwords != nil {
+        for _, common := range t.Passwords.CommonPasswords {
+            if strings.ToLower(common) == password {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// passwordEntropy estimates the entropy of password in bits
+func passwordEntropy(password string) float64 {
+    var (
+        lower, upper, digit, symbol, other bool
+        effective                          int
+        previous                           rune
+    )
+    for i, r := range []rune(password) {
+        switch {
+        case r >= 'a' && r <= 'z':
+            lower = true
+        case r >= 'A' && r <= 'Z':
+            upper = true
+        case r >= '0' && r <= '9':
+            digit = true
+        case r < unicode.MaxASCII && unicode.IsPrint(r):
+            symbol = true
+        default:
+            other = true
+        }
+
+        // repeated characters and sequences such as abc or 321 add little
+        d := r - previous
+        if i == 0 || (d != 0 && d != 1 && d != -1) {
+            effective++
+        }
+        previous = r
+    }
+
+    pool := 0
+    for _, class := range []struct {
+        used bool
+        size int
+    }{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+        if class.used {
+            pool += class.size
+        }
+    }
+    if pool == 0 {
+        return 0
+    }
+    return float64(effective) * math.Log2(float64(pool))
+}
+
+// commonPasswords holds some of the most used passwords, from public breach compilations
+var commonPasswords = map[string]struct{}{}
+
+func init() {
+    for _, p := range strings.Fields(`
+        123456 123456789 12345678 password qwerty123 qwerty 12345 1234567 111111 1234567890
+        123123 abc123 1234 password1 iloveyou 1q2w3e4r 000000 qwertyuiop 123321 dragon
+        654321 666666 monkey 7777777 1qaz2wsx 123qwe 121212 football baseball welcome
+        welcome1 sunshine princess letmein admin admin123 administrator passw0rd p@ssw0rd
+        p@ssword master michael shadow superman batman trustno1 hello123 charlie donald
+        freedom whatever qazwsx zaq12wsx 987654321 aaaaaa 88888888 11111111 00000000
+        asdfghjk asdfgh asdf1234 zxcvbnm zxcvbnm1 1qazxsw2 q1w2e3r4 q1w2e3r4t5 mustang
+        access starwars jessica michelle jennifer hunter2 hunter ninja azerty loveme
+        lovely flower hottie secret secret123 summer2023 winter2023 spring2024 autumn2024
+        changeme default guest login test test123 testing root toor computer internet
+        google samsung chocolate cookie pokemon liverpool chelsea arsenal soccer hockey
+        killer jordan23 harley ranger buster tigger thomas robert daniel andrew joshua
+        matrix mercedes corvette ferrari porsche blink182 solo pepper ginger maggie
+        password123 password12 password!
+    `) {
+        commonPasswords[p] = struct{}{}
+    }
+}
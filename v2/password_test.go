@@ -0,0 +1,172 @@
+package toolkit
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "strings"
+    "testing"
+)
+
+var pbkdf2Tests = []struct {
+    password   string
+    salt       string
+    iterations int
+    expected   string
+}{
+    // RFC 7914 section 11
+    {password: "passwd", salt: "salt", iterations: 1, expected: "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+    {password: "password", salt: "salt", iterations: 4096, expected: "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+    {password: "passwordPASSWORDpassword", salt: "saltSALTsaltSALTsaltSALTsaltSALTsalt", iterations: 4096, expected: "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+    for _, test := range pbkdf2Tests {
+        key := pbkdf2SHA256([]byte(test.password), []byte(test.salt), test.iterations, len(test.expected)/2)
+        if hex.EncodeToString(key) != test.expected {
+            t.Errorf("%s/%s/%d: expected %s, got %x", test.password, test.salt, test.iterations, test.expected, key)
+        }
+    }
+}
+
+func TestTools_HashPassword(t *testing.T) {
+    testTools := Tools{Passwords: &PasswordPolicy{Iterations: 1000}}
+
+    hash, err := testTools.HashPassword("correct horse battery staple")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(hash, "$pbkdf2-sha256$i=1000$") {
+        t.Errorf("unexpected hash %s", hash)
+    }
+
+    other, _ := testTools.HashPassword("correct horse battery staple")
+    if other == hash {
+        t.Error("hashes are not salted")
+    }
+
+    ok, err := testTools.VerifyPassword("correct horse battery staple", hash)
+    if err != nil || !ok {
+        t.Errorf("password does not match its hash: %v", err)
+    }
+    if ok, _ = testTools.VerifyPassword("Correct horse battery staple", hash); ok {
+        t.Error("wrong password matches")
+    }
+
+    randReader = failingReader{}
+    defer func() { randReader = rand.Reader }()
+    if _, err = testTools.HashPassword("password"); err == nil {
+        t.Error("entropy failure: error expected, but none received")
+    }
+}
+
+func TestTools_HashPassword_Defaults(t *testing.T) {
+    if testing.Short() {
+        t.Skip("hashing with the default iterations is slow")
+    }
+    var testTools Tools
+
+    hash, err := testTools.HashPassword("secret")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(hash, "$pbkdf2-sha256$i=600000$") || testTools.PasswordNeedsRehash(hash) {
+        t.Errorf("unexpected hash %s", hash)
+    }
+}
+
+var verifyPasswordTests = []string{
+    "",
+    "pbkdf2-sha256$i=1000$c2FsdA$a2V5",
+    "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$a2V5",
+    "$pbkdf2-sha256$1000$c2FsdA$a2V5",
+    "$pbkdf2-sha256$i=0$c2FsdA$a2V5",
+    "$pbkdf2-sha256$i=1000$not base64$a2V5",
+    "$pbkdf2-sha256$i=1000$c2FsdA$",
+    "$pbkdf2-sha256$i=1000$c2FsdA$a2V5$extra",
+}
+
+func TestTools_VerifyPassword_Malformed(t *testing.T) {
+    var testTools Tools
+
+    for _, hash := range verifyPasswordTests {
+        ok, err := testTools.VerifyPassword("password", hash)
+        if ok || err == nil {
+            t.Errorf("%q: error expected, but none received", hash)
+        } else if !errors.Is(err, ErrInvalidPasswordHash) {
+            t.Errorf("%q: error does not wrap ErrInvalidPasswordHash", hash)
+        }
+    }
+}
+
+func TestTools_PasswordNeedsRehash(t *testing.T) {
+    old := Tools{Passwords: &PasswordPolicy{Iterations: 1000, SaltLength: 8}}
+    hash, _ := old.HashPassword("password")
+
+    var tests = []struct {
+        name     string
+        policy   *PasswordPolicy
+        expected bool
+    }{
+        {name: "same", policy: &PasswordPolicy{Iterations: 1000, SaltLength: 8}, expected: false},
+        {name: "fewer iterations", policy: &PasswordPolicy{Iterations: 500, SaltLength: 8}, expected: false},
+        {name: "more iterations", policy: &PasswordPolicy{Iterations: 2000, SaltLength: 8}, expected: true},
+        {name: "longer salt", policy: &PasswordPolicy{Iterations: 1000}, expected: true},
+        {name: "longer key", policy: &PasswordPolicy{Iterations: 1000, SaltLength: 8, KeyLength: 64}, expected: true},
+        {name: "defaults", policy: nil, expected: true},
+    }
+    for _, test := range tests {
+        testTools := Tools{Passwords: test.policy}
+        if got := testTools.PasswordNeedsRehash(hash); got != test.expected {
+            t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+        }
+    }
+
+    var testTools Tools
+    if !testTools.PasswordNeedsRehash("$2a$10$bcrypt") {
+        t.Error("malformed hash does not need a rehash")
+    }
+}
+
+var passwordStrengthTests = []struct {
+    name     string
+    password string
+    common   bool
+    ok       bool
+}{
+    {name: "too short", password: "x9#Kq", common: false, ok: false},
+    {name: "common", password: "password1", common: true, ok: false},
+    {name: "common in upper case", password: "LetMeIn", common: true, ok: false},
+    {name: "custom common", password: "tiqet2024", common: true, ok: false},
+    {name: "repeated", password: "aaaaaaaaaaaa", common: false, ok: false},
+    {name: "sequence", password: "abcdefghijkl", common: false, ok: false},
+    {name: "digits only", password: "83920174", common: false, ok: false},
+    {name: "passphrase", password: "correct horse battery staple", common: false, ok: true},
+    {name: "mixed", password: "Tr0ub4dor&3", common: false, ok: true},
+    {name: "unicode", password: "ĉevalo-ŝtono-pluvo", common: false, ok: true},
+}
+
+func TestTools_CheckPasswordStrength(t *testing.T) {
+    testTools := Tools{Passwords: &PasswordPolicy{CommonPasswords: []string{"Tiqet2024"}}}
+
+    for _, test := range passwordStrengthTests {
+        strength := testTools.CheckPasswordStrength(test.password)
+        if strength.Common != test.common {
+            t.Errorf("%s: expected common %v, got %v", test.name, test.common, strength.Common)
+        }
+        if strength.OK() != test.ok {
+            t.Errorf("%s: expected ok %v, got %v (entropy %.1f, problems %v)", test.name, test.ok, strength.OK(), strength.Entropy, strength.Problems)
+        }
+    }
+
+    if strength := testTools.CheckPasswordStrength("ĉevalo"); strength.Length != 6 {
+        t.Errorf("expected 6 characters, got %d", strength.Length)
+    }
+}
+
+func BenchmarkTools_HashPassword(b *testing.B) {
+    var testTools Tools
+    for i := 0; i < b.N; i++ {
+        _, _ = testTools.HashPassword("correct horse battery staple")
+    }
+}
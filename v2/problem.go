@@ -0,0 +1,176 @@
+package toolkit
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+)
+
+// ErrorFormat selects the body written by ErrorJSON
+type ErrorFormat int
+
+const (
+    // ErrorFormatLegacy writes a JSONResponse, and is the default
+    ErrorFormatLegacy ErrorFormat = iota
+    // ErrorFormatProblem writes RFC 9457 problem details as application/problem+json
+    ErrorFormatProblem
+)
+
+// MarshalText encodes f as "legacy" or "problem", for use in configuration files
+func (f ErrorFormat) MarshalText() ([]byte, error) {
+    switch f {
+    case ErrorFormatLegacy:
+        return []byte("legacy"), nil
+    case ErrorFormatProblem:
+        return []byte("problem"), nil
+    }
+    return nil, fmt.Errorf("unknown error format %d", f)
+}
+
+// UnmarshalText decodes "legacy" or "problem", in any case
+func (f *ErrorFormat) UnmarshalText(text []byte) error {
+    switch strings.ToLower(strings.TrimSpace(string(text))) {
+    case "legacy":
+        *f = ErrorFormatLegacy
+    case "problem":
+        *f = ErrorFormatProblem
+    default:
+        return fmt.Errorf("unknown error format %q, expected legacy or problem", text)
+    }
+    return nil
+}
+
+// ErrorRenderer writes an error response in a user defined format
+type ErrorRenderer func(w http.ResponseWriter, err error, status int) error
+
+// Problem is an error carrying RFC 9457 problem details. Handlers can return it to control
+// the status code, title and extension members of the response written by ErrorJSON
+type Problem struct {
+    // Type is a URI reference identifying the problem type, "about:blank" when empty
+    Type     string
+    Title    string
+    Status   int
+    Detail   string
+    Instance string
+    // Extensions are additional members serialized alongside the standard ones
+    Extensions map[string]interface{}
+    // Err is the underlying cause; it is never sent to clients
+    Err error
+}
+
+// NewProblem returns a Problem with the given status, a title taken from the status text and detail
+func NewProblem(status int, detail string) *Problem {
+    return &Problem{Status: status, Title: http.StatusText(status), Detail: detail}
+}
+
+// Error returns the detail of the problem, or its title when there is no detail
+func (p *Problem) Error() string {
+    switch {
+    case p.Detail != "":
+        return p.Detail
+    case p.Title != "":
+        return p.Title
+    case p.Err != nil:
+        return p.Err.Error()
+    }
+    return http.StatusText(p.Status)
+}
+
+// Unwrap returns the underlying cause of the problem
+func (p *Problem) Unwrap() error {
+    return p.Err
+}
+
+// MarshalJSON writes the standard members together with the extension members
+func (p Problem) MarshalJSON() ([]byte, error) {
+    members := make(map[string]interface{}, len(p.Extensions)+5)
+    for k, v := range p.Extensions {
+        members[k] = v
+    }
+
+    members["type"] = p.Type
+    if p.Type == "" {
+        members["type"] = "about:blank"
+    }
+    if p.Title != "" {
+        members["title"] = p.Title
+    }
+    if p.Status != 0 {
+        members["status"] = p.Status
+    }
+    if p.Detail != "" {
+        members["detail"] = p.Detail
+    }
+    if p.Instance != "" {
+        members["instance"] = p.Instance
+    }
+    return json.Marshal(members)
+}
+
+// UnmarshalJSON reads problem details, collecting unknown members into Extensions
+func (p *Problem) UnmarshalJSON(data []byte) error {
+    var members map[string]json.RawMessage
+    if err := json.Unmarshal(data, &members); err != nil {
+        return err
+    }
+
+    standard := map[string]interface{}{
+        "type":     &p.Type,
+        "title":    &p.Title,
+        "status":   &p.Status,
+        "detail":   &p.Detail,
+        "instance": &p.Instance,
+    }
+    for k, raw := range members {
+        if target, ok := standard[k]; ok {
+            // members of the wrong type are ignored, as RFC 9457 requires
+            _ = json.Unmarshal(raw, target)
+            continue
+        }
+        var v interface{}
+        if err := json.Unmarshal(raw, &v); err != nil {
+            return err
+        }
+        if p.Extensions == nil {
+            p.Extensions = make(map[string]interface{})
+        }
+        p.Extensions[k] = v
+    }
+    return nil
+}
+
+// problemFor builds the problem details describing err sent with status
+func problemFor(err error, status int) Problem {
+    var (
+        problem Problem
+        p       *Problem
+    )
+    if errors.As(err, &p) {
+        problem = *p
+    } else {
+        problem.Detail = err.Error()
+    }
+
+    problem.Status = status
+    if problem.Title == "" {
+        problem.Title = http.StatusText(status)
+    }
+
+    var validationErrors ValidationErrors
+    if errors.As(err, &validationErrors) {
+        problem.Detail = "validation failed"
+        problem.Extensions = copyExtensions(problem.Extensions)
+        problem.Extensions["errors"] = validationErrors
+    }
+    return problem
+}
+
+func copyExtensions(extensions map[string]interface{}) map[string]interface{} {
+    out := make(map[string]interface{}, len(extensions)+1)
+    for k, v := range extensions {
+        out[k] = v
+    }
+    return out
+}
@@ -0,0 +1,151 @@
+package toolkit
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestTools_ErrorJSONProblem(t *testing.T) {
+    testTools := Tools{ErrorFormat: ErrorFormatProblem}
+
+    problem := NewProblem(http.StatusConflict, "order already shipped")
+    problem.Type = "https://example.com/problems/shipped"
+    problem.Instance = "/orders/42"
+    problem.Extensions = map[string]interface{}{"orderId": 42}
+    problem.Err = errors.New("sql: constraint violation")
+
+    rr := httptest.NewRecorder()
+    err := testTools.ErrorJSON(rr, fmt.Errorf("handler: %w", problem))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if rr.Code != http.StatusConflict {
+        t.Errorf("expected status code 409, got: %d", rr.Code)
+    }
+    if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+        t.Errorf("wrong content type %s", ct)
+    }
+
+    var body map[string]interface{}
+    if err = json.NewDecoder(rr.Body).Decode(&body); err != nil {
+        t.Fatal("received error when decoding JSON:", err)
+    }
+    expected := map[string]interface{}{
+        "type":     "https://example.com/problems/shipped",
+        "title":    "Conflict",
+        "status":   float64(409),
+        "detail":   "order already shipped",
+        "instance": "/orders/42",
+        "orderId":  float64(42),
+    }
+    if len(body) != len(expected) {
+        t.Errorf("expected %v, got %v", expected, body)
+    }
+    for k, v := range expected {
+        if body[k] != v {
+            t.Errorf("member %s: expected %v, got %v", k, v, body[k])
+        }
+    }
+}
+
+func TestTools_ErrorJSONProblemPlainError(t *testing.T) {
+    testTools := Tools{ErrorFormat: ErrorFormatProblem}
+
+    rr := httptest.NewRecorder()
+    if err := testTools.ErrorJSON(rr, errors.New("some error"), http.StatusServiceUnavailable); err != nil {
+        t.Fatal(err)
+    }
+
+    var problem Problem
+    if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+        t.Fatal("received error when decoding JSON:", err)
+    }
+    if problem.Type != "about:blank" || problem.Status != 503 || problem.Title != "Service Unavailable" || problem.Detail != "some error" {
+        t.Errorf("unexpected problem details %+v", problem)
+    }
+}
+
+func TestTools_ErrorJSONLegacyProblem(t *testing.T) {
+    var testTools Tools
+
+    rr := httptest.NewRecorder()
+    problem := &Problem{Status: http.StatusNotFound, Title: "Not Found", Extensions: map[string]interface{}{"id": "7"}}
+    if err := testTools.ErrorJSON(rr, problem); err != nil {
+        t.Fatal(err)
+    }
+
+    var payload JSONResponse
+    if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+        t.Fatal("received error when decoding JSON:", err)
+    }
+    if rr.Code != http.StatusNotFound || payload.Message != "Not Found" || !payload.Error {
+        t.Errorf("unexpected response %d %+v", rr.Code, payload)
+    }
+    if data, ok := payload.Data.(map[string]interface{}); !ok || data["id"] != "7" {
+        t.Errorf("expected extensions in data, got %v", payload.Data)
+    }
+}
+
+func TestTools_ErrorJSONRenderer(t *testing.T) {
+    var received int
+    testTools := Tools{
+        ErrorRenderer: func(w http.ResponseWriter, err error, status int) error {
+            received = status
+            w.WriteHeader(status)
+            _, werr := w.Write([]byte(err.Error()))
+            return werr
+        },
+    }
+
+    rr := httptest.NewRecorder()
+    if err := testTools.ErrorJSON(rr, errors.New("teapot"), http.StatusTeapot); err != nil {
+        t.Fatal(err)
+    }
+    if received != http.StatusTeapot || rr.Body.String() != "teapot" {
+        t.Errorf("renderer not used: %d %q", received, rr.Body.String())
+    }
+}
+
+func TestProblem_JSONRoundTrip(t *testing.T) {
+    in := `{"type":"about:blank","title":"Bad","status":"not a number","balance":30}`
+
+    var problem Problem
+    if err := json.Unmarshal([]byte(in), &problem); err != nil {
+        t.Fatal(err)
+    }
+    if problem.Title != "Bad" || problem.Status != 0 || problem.Extensions["balance"] != float64(30) {
+        t.Errorf("unexpected problem %+v", problem)
+    }
+    if problem.Error() != "Bad" {
+        t.Errorf("unexpected error text %q", problem.Error())
+    }
+}
+
+func TestErrorFormat_TextRoundTrip(t *testing.T) {
+    for _, f := range []ErrorFormat{ErrorFormatLegacy, ErrorFormatProblem} {
+        text, err := f.MarshalText()
+        if err != nil {
+            t.Fatal(err)
+        }
+        var decoded ErrorFormat
+        if err = decoded.UnmarshalText(text); err != nil || decoded != f {
+            t.Errorf("%s: round trip gave %d, %v", text, decoded, err)
+        }
+    }
+
+    var f ErrorFormat
+    if err := f.UnmarshalText([]byte("Problem")); err != nil || f != ErrorFormatProblem {
+        t.Errorf("expected case insensitive decoding, got %d, %v", f, err)
+    }
+    if err := f.UnmarshalText([]byte("html")); err == nil {
+        t.Error("error expected, but none received")
+    }
+    if _, err := ErrorFormat(7).MarshalText(); err == nil {
+        t.Error("error expected, but none received")
+    }
+}
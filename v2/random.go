@@ -0,0 +1,100 @@
+package toolkit
+
+import (
+    "crypto/rand"
+    "errors"
+    "fmt"
+    "io"
+    "math/bits"
+)
+
+// Alphabets for RandomStringFrom
+const (
+    // AlphabetURLSafe holds the 64 characters of unpadded base64url, safe in URLs and file names
+    AlphabetURLSafe = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+    // AlphabetAlphanumeric holds upper and lower case letters and digits
+    AlphabetAlphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+    // AlphabetHex holds lower case hexadecimal digits
+    AlphabetHex = "0123456789abcdef"
+    // AlphabetCrockford holds the Crockford base32 digits, which exclude I, L, O and U
+    AlphabetCrockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+    // AlphabetNumeric holds decimal digits
+    AlphabetNumeric = "0123456789"
+    // AlphabetNoLookalike leaves out characters easily confused when read: 0 O o 1 I l i j u v
+    AlphabetNoLookalike = "23456789abcdefghkmnpqrstwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// randomStringSource is the alphabet used by RandomString
+const randomStringSource = AlphabetURLSafe
+
+// randReader is the source of entropy of random strings, replaced by tests
+var randReader io.Reader = rand.Reader
+
+// RandomString returns a string of random characters of length n, taken from
+// randomStringSource (AlphabetURLSafe). It panics if the system source of entropy fails;
+// use RandomStringFrom to handle that error
+func (t *Tools) RandomString(n int) string {
+    s, err := t.RandomStringFrom(n, randomStringSource)
+    if err != nil {
+        panic(err)
+    }
+    return s
+}
+
+// RandomStringFrom returns a string of n characters chosen uniformly from alphabet, which must
+// hold between 2 and 256 distinct ASCII characters. Random bytes are read from crypto/rand in
+// batches and masked to the smallest power of two covering the alphabet; values outside it are
+// rejected rather than reduced modulo its length, so that no character is more likely than another
+func (t *Tools) RandomStringFrom(n int, alphabet string) (string, error) {
+    if err := checkAlphabet(alphabet); err != nil {
+        return "", err
+    }
+    if n <= 0 {
+        return "", nil
+    }
+
+    size := len(alphabet)
+    mask := byte(uint(1)<<bits.Len(uint(size-1)) - 1)
+
+    // read enough bytes for the expected number of rejections, plus a margin
+    step := n * (int(mask) + 1) / size
+    step += step/2 + 1
+    if step > 4096 {
+        step = 4096
+    }
+    buf := make([]byte, step)
+
+    s := make([]byte, 0, n)
+    for {
+        if _, err := io.ReadFull(randReader, buf); err != nil {
+            return "", fmt.Errorf("error reading random bytes: %w", err)
+        }
+        for _, b := range buf {
+            if b &= mask; int(b) < size {
+                s = append(s, alphabet[b])
+                if len(s) == n {
+                    return string(s), nil
+                }
+            }
+        }
+    }
+}
+
+// checkAlphabet verifies that alphabet can be used by RandomStringFrom
+func checkAlphabet(alphabet string) error {
+    if len(alphabet) < 2 || len(alphabet) > 256 {
+        return errors.New("alphabet must hold between 2 and 256 characters")
+    }
+    var seen [256]bool
+    for i := 0; i < len(alphabet); i++ {
+        c := alphabet[i]
+        if c > 127 {
+            return errors.New("alphabet must hold ASCII characters only")
+        }
+        if seen[c] {
+            return fmt.Errorf("alphabet holds %q more than once", c)
+        }
+        seen[c] = true
+    }
+    return nil
+}
@@ -0,0 +1,164 @@
+package toolkit
+
+import (
+    "crypto/rand"
+    "errors"
+    "math/big"
+    "strings"
+    "testing"
+)
+
+var randomStringTests = []struct {
+    name          string
+    alphabet      string
+    length        int
+    errorExpected bool
+}{
+    {name: "url safe", alphabet: AlphabetURLSafe, length: 32, errorExpected: false},
+    {name: "alphanumeric", alphabet: AlphabetAlphanumeric, length: 32, errorExpected: false},
+    {name: "hex", alphabet: AlphabetHex, length: 40, errorExpected: false},
+    {name: "crockford", alphabet: AlphabetCrockford, length: 26, errorExpected: false},
+    {name: "numeric", alphabet: AlphabetNumeric, length: 6, errorExpected: false},
+    {name: "no lookalike", alphabet: AlphabetNoLookalike, length: 12, errorExpected: false},
+    {name: "binary", alphabet: "01", length: 64, errorExpected: false},
+    {name: "zero length", alphabet: AlphabetHex, length: 0, errorExpected: false},
+    {name: "empty alphabet", alphabet: "", length: 10, errorExpected: true},
+    {name: "single character", alphabet: "a", length: 10, errorExpected: true},
+    {name: "duplicate character", alphabet: "abca", length: 10, errorExpected: true},
+    {name: "non ascii", alphabet: "abcé", length: 10, errorExpected: true},
+}
+
+func TestTools_RandomStringFrom(t *testing.T) {
+    var testTools Tools
+
+    for _, test := range randomStringTests {
+        s, err := testTools.RandomStringFrom(test.length, test.alphabet)
+        if err == nil && test.errorExpected {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+        if err != nil && !test.errorExpected {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        if err != nil {
+            continue
+        }
+
+        if len(s) != test.length {
+            t.Errorf("%s: expected length %d, got %d", test.name, test.length, len(s))
+        }
+        for _, c := range s {
+            if !strings.ContainsRune(test.alphabet, c) {
+                t.Errorf("%s: %q is not in the alphabet", test.name, c)
+            }
+        }
+    }
+}
+
+func TestTools_RandomString_URLSafe(t *testing.T) {
+    var testTools Tools
+
+    s := testTools.RandomString(1000)
+    if strings.Trim(s, AlphabetURLSafe) != "" {
+        t.Errorf("unexpected characters in %q", s)
+    }
+}
+
+func TestTools_RandomString_Uniform(t *testing.T) {
+    var testTools Tools
+
+    // with an alphabet of 10 characters, a biased modulo would favor the first 6
+    const samples = 100000
+    s, err := testTools.RandomStringFrom(samples, AlphabetNumeric)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    counts := make(map[rune]int)
+    for _, c := range s {
+        counts[c]++
+    }
+
+    // chi-squared with 9 degrees of freedom; 27.88 is the 0.001 critical value
+    expected := float64(samples) / float64(len(AlphabetNumeric))
+    var chi2 float64
+    for _, c := range AlphabetNumeric {
+        d := float64(counts[c]) - expected
+        chi2 += d * d / expected
+    }
+    if chi2 > 27.88 {
+        t.Errorf("distribution is not uniform: chi-squared %.2f, counts %v", chi2, counts)
+    }
+}
+
+// failingReader is a source of entropy which always fails
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+    return 0, errors.New("entropy exhausted")
+}
+
+func TestTools_RandomStringFrom_EntropyFailure(t *testing.T) {
+    var testTools Tools
+
+    randReader = failingReader{}
+    defer func() { randReader = rand.Reader }()
+
+    if _, err := testTools.RandomStringFrom(10, AlphabetURLSafe); err == nil {
+        t.Error("error expected, but none received")
+    }
+
+    defer func() {
+        if recover() == nil {
+            t.Error("RandomString did not panic")
+        }
+    }()
+    testTools.RandomString(10)
+}
+
+// randomStringPrime is the previous implementation of RandomString, kept for comparison
+func randomStringPrime(n int) string {
+    s, r := make([]rune, n), []rune(AlphabetURLSafe)
+    for i := range s {
+        p, _ := rand.Prime(rand.Reader, len(r))
+        x, y := p.Uint64(), uint64(len(r))
+        s[i] = r[x%y]
+    }
+    return string(s)
+}
+
+func BenchmarkRandomString(b *testing.B) {
+    var testTools Tools
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        testTools.RandomString(32)
+    }
+}
+
+func BenchmarkRandomString_Crockford(b *testing.B) {
+    var testTools Tools
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        _, _ = testTools.RandomStringFrom(26, AlphabetCrockford)
+    }
+}
+
+func BenchmarkRandomString_Prime(b *testing.B) {
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        randomStringPrime(32)
+    }
+}
+
+func BenchmarkRandomString_BigInt(b *testing.B) {
+    // a uniform choice with crypto/rand.Int, one call per character
+    max := big.NewInt(int64(len(AlphabetURLSafe)))
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        s := make([]byte, 32)
+        for j := range s {
+            k, _ := rand.Int(rand.Reader, max)
+            s[j] = AlphabetURLSafe[k.Int64()]
+        }
+    }
+}
+
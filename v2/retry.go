@@ -0,0 +1,274 @@
+package toolkit
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "math/rand"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// ErrCircuitOpen is returned when a request is refused because the circuit breaker of its host is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// RetryPolicy configures how PushJSONToRemote retries failed calls. Network errors and
+// responses with status 429 or 5xx are retried with exponential backoff and jitter;
+// a Retry-After header is honored, unless it asks to wait longer than MaxBackoff
+type RetryPolicy struct {
+    // MaxAttempts is the total number of attempts, including the first one; 3 if zero
+    MaxAttempts int
+    // InitialBackoff is the wait before the first retry, 100ms if zero
+    InitialBackoff time.Duration
+    // MaxBackoff caps the wait between attempts, 10s if zero
+    MaxBackoff time.Duration
+    // Multiplier is applied to the backoff after every attempt, 2 if zero
+    Multiplier float64
+    // Jitter is the fraction of the backoff which is randomized, between 0 and 1
+    Jitter float64
+    // IdempotencyKey adds an Idempotency-Key header, identical for all attempts of a call
+    IdempotencyKey bool
+}
+
+// IdempotencyKeyHeader is the header carrying the key generated when RetryPolicy.IdempotencyKey is set
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+func (p *RetryPolicy) attempts() int {
+    if p == nil {
+        return 1
+    }
+    if p.MaxAttempts <= 0 {
+        return 3
+    }
+    return p.MaxAttempts
+}
+
+// backoff returns the wait before retry number n, starting at 1
+func (p *RetryPolicy) backoff(n int) time.Duration {
+    initial, maxBackoff, multiplier := p.InitialBackoff, p.maxBackoff(), p.Multiplier
+    if initial <= 0 {
+        initial = 100 * time.Millisecond
+    }
+    if multiplier <= 0 {
+        multiplier = 2
+    }
+
+    d := float64(initial) * math.Pow(multiplier, float64(n-1))
+    if d > float64(maxBackoff) {
+        d = float64(maxBackoff)
+    }
+    if p.Jitter > 0 {
+        d -= d * math.Min(p.Jitter, 1) * rand.Float64()
+    }
+    return time.Duration(d)
+}
+
+func (p *RetryPolicy) maxBackoff() time.Duration {
+    if p.MaxBackoff <= 0 {
+        return 10 * time.Second
+    }
+    return p.MaxBackoff
+}
+
+// retryable reports whether a call which returned response and err should be attempted again
+func retryable(response *http.Response, err error) bool {
+    if err != nil {
+        return true
+    }
+    return response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+}
+
+// retryAfter parses the Retry-After header of response, given in seconds or as an HTTP date
+func retryAfter(response *http.Response, now time.Time) (time.Duration, bool) {
+    if response == nil {
+        return 0, false
+    }
+    value := response.Header.Get("Retry-After")
+    if value == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+        return time.Duration(seconds) * time.Second, true
+    }
+    if date, err := http.ParseTime(value); err == nil {
+        if d := date.Sub(now); d > 0 {
+            return d, true
+        }
+        return 0, true
+    }
+    return 0, false
+}
+
+// CircuitBreaker tracks failures per host and fails fast once a host has failed
+// FailureThreshold times in a row. After OpenTimeout a single probe request is let through:
+// its success closes the circuit again, its failure keeps it open for another OpenTimeout.
+// A CircuitBreaker is safe for concurrent use
+type CircuitBreaker struct {
+    // FailureThreshold is the number of consecutive failures opening the circuit, 5 if zero
+    FailureThreshold int
+    // OpenTimeout is how long the circuit stays open before a probe is allowed, 30s if zero
+    OpenTimeout time.Duration
+
+    mu    sync.Mutex
+    hosts map[string]*breakerState
+    now   func() time.Time
+}
+
+// breakerState is the state of the circuit of a single host
+type breakerState struct {
+    failures  int
+    openUntil time.Time
+    probing   bool
+}
+
+// NewCircuitBreaker returns a circuit breaker opening after threshold consecutive failures
+// and allowing a probe after openTimeout
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+    return &CircuitBreaker{FailureThreshold: threshold, OpenTimeout: openTimeout}
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+    if b.now != nil {
+        return b.now()
+    }
+    return time.Now()
+}
+
+// Allow returns ErrCircuitOpen if requests to host must not be attempted
+func (b *CircuitBreaker) Allow(host string) error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    state, ok := b.hosts[host]
+    if !ok || state.openUntil.IsZero() {
+        return nil
+    }
+    if state.probing || b.clock().Before(state.openUntil) {
+        return fmt.Errorf("%w for %s", ErrCircuitOpen, host)
+    }
+    state.probing = true
+    return nil
+}
+
+// Record reports the outcome of a request to host
+func (b *CircuitBreaker) Record(host string, success bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if b.hosts == nil {
+        b.hosts = make(map[string]*breakerState)
+    }
+    state, ok := b.hosts[host]
+    if !ok {
+        state = &breakerState{}
+        b.hosts[host] = state
+    }
+
+    if success {
+        *state = breakerState{}
+        return
+    }
+
+    threshold := b.FailureThreshold
+    if threshold <= 0 {
+        threshold = 5
+    }
+
+    state.failures++
+    state.probing = false
+    if state.failures >= threshold {
+        state.openUntil = b.clock().Add(b.openTimeout())
+    }
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+    if b.OpenTimeout <= 0 {
+        return 30 * time.Second
+    }
+    return b.OpenTimeout
+}
+
+// release clears the probe of host without recording an outcome, so that a request
+// abandoned by the caller does not leave a half-open circuit waiting forever
+func (b *CircuitBreaker) release(host string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if state, ok := b.hosts[host]; ok {
+        state.probing = false
+    }
+}
+
+// doWithRetry sends the requests built by newRequest, retrying according to t.Retry and
+// consulting t.CircuitBreaker before every attempt. Waiting between attempts stops when ctx is done,
+// and attempts canceled through ctx are not counted as failures of the host
+func (t *Tools) doWithRetry(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+    var idempotencyKey string
+    if t.Retry != nil && t.Retry.IdempotencyKey {
+        idempotencyKey = t.RandomString(32)
+    }
+
+    attempts := t.Retry.attempts()
+    for attempt := 1; ; attempt++ {
+        request, err := newRequest()
+        if err != nil {
+            return nil, err
+        }
+        if idempotencyKey != "" {
+            request.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+        }
+
+        host := request.URL.Host
+        if t.CircuitBreaker != nil {
+            if err = t.CircuitBreaker.Allow(host); err != nil {
+                return nil, err
+            }
+        }
+
+        response, err := client.Do(request)
+        switch {
+        case t.CircuitBreaker == nil:
+        case err != nil && errors.Is(ctx.Err(), context.Canceled):
+            // the caller gave up, which says nothing about the health of the host. An expired
+            // deadline does count, as a host hanging until the timeout is not healthy
+            t.CircuitBreaker.release(host)
+        default:
+            t.CircuitBreaker.Record(host, err == nil && response.StatusCode < http.StatusInternalServerError)
+        }
+
+        if attempt >= attempts || !retryable(response, err) {
+            return response, err
+        }
+
+        wait := t.Retry.backoff(attempt)
+        if d, ok := retryAfter(response, time.Now()); ok {
+            if d > t.Retry.maxBackoff() {
+                // the server asks for more patience than we have
+                return response, err
+            }
+            wait = d
+        }
+        if response != nil {
+            drainAndClose(response)
+        }
+
+        timer := time.NewTimer(wait)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return nil, ctx.Err()
+        case <-timer.C:
+        }
+    }
+}
+
+// drainAndClose discards a bounded amount of the body of response and closes it,
+// so that the underlying connection can be reused
+func drainAndClose(response *http.Response) {
+    _, _ = io.CopyN(io.Discard, response.Body, 64*1024)
+    _ = response.Body.Close()
+}
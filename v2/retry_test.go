@@ -0,0 +1,163 @@
+package toolkit
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// flakyServer fails the first failures requests with status, then answers 200
+func flakyServer(failures int32, status int, header http.Header) (*httptest.Server, *int32, *[]string) {
+    var (
+        calls int32
+        keys  []string
+    )
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+        if atomic.AddInt32(&calls, 1) <= failures {
+            for k, v := range header {
+                w.Header()[k] = v
+            }
+            w.WriteHeader(status)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+    }))
+    return srv, &calls, &keys
+}
+
+var retryTests = []struct {
+    name           string
+    failures       int32
+    status         int
+    header         http.Header
+    maxAttempts    int
+    expectedCalls  int32
+    expectedStatus int
+}{
+    {name: "success", failures: 0, maxAttempts: 3, expectedCalls: 1, expectedStatus: http.StatusOK},
+    {name: "recovers after 5xx", failures: 2, status: http.StatusBadGateway, maxAttempts: 3, expectedCalls: 3, expectedStatus: http.StatusOK},
+    {name: "gives up", failures: 5, status: http.StatusServiceUnavailable, maxAttempts: 3, expectedCalls: 3, expectedStatus: http.StatusServiceUnavailable},
+    {name: "429 with retry-after", failures: 1, status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"0"}}, maxAttempts: 3, expectedCalls: 2, expectedStatus: http.StatusOK},
+    {name: "retry-after too long", failures: 1, status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"3600"}}, maxAttempts: 3, expectedCalls: 1, expectedStatus: http.StatusTooManyRequests},
+    {name: "4xx not retried", failures: 1, status: http.StatusBadRequest, maxAttempts: 3, expectedCalls: 1, expectedStatus: http.StatusBadRequest},
+}
+
+func TestTools_PushJSONToRemoteRetry(t *testing.T) {
+    for _, test := range retryTests {
+        srv, calls, keys := flakyServer(test.failures, test.status, test.header)
+
+        testTools := Tools{Retry: &RetryPolicy{
+            MaxAttempts:    test.maxAttempts,
+            InitialBackoff: time.Millisecond,
+            MaxBackoff:     10 * time.Millisecond,
+            Jitter:         0.5,
+            IdempotencyKey: true,
+        }}
+        _, status, err := testTools.PushJSONToRemote(srv.URL, map[string]string{"foo": "bar"})
+        srv.Close()
+
+        if err != nil {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        if status != test.expectedStatus {
+            t.Errorf("%s: expected status %d, got %d", test.name, test.expectedStatus, status)
+        }
+        if *calls != test.expectedCalls {
+            t.Errorf("%s: expected %d calls, got %d", test.name, test.expectedCalls, *calls)
+        }
+        for _, key := range *keys {
+            if key == "" || key != (*keys)[0] {
+                t.Errorf("%s: expected one idempotency key for all attempts, got %v", test.name, *keys)
+                break
+            }
+        }
+    }
+}
+
+func TestTools_PushJSONToRemoteNetworkError(t *testing.T) {
+    var calls int32
+    client := NewTestClient(nil)
+    client.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+        atomic.AddInt32(&calls, 1)
+        return nil, errors.New("connection reset")
+    })
+
+    testTools := Tools{Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}}
+    _, _, err := testTools.PushJSONToRemote("http://example.com/hook", "x", client)
+    if err == nil {
+        t.Error("expected error")
+    }
+    if calls != 2 {
+        t.Errorf("expected 2 calls, got %d", calls)
+    }
+}
+
+// roundTripperFunc is a transport which may also fail with an error
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+    return f(req)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+    now := time.Now()
+    breaker := NewCircuitBreaker(2, time.Minute)
+    breaker.now = func() time.Time { return now }
+
+    srv, calls, _ := flakyServer(100, http.StatusInternalServerError, nil)
+    defer srv.Close()
+    host := mustHost(t, srv.URL)
+
+    testTools := Tools{CircuitBreaker: breaker}
+    for i := 0; i < 2; i++ {
+        if _, _, err := testTools.PushJSONToRemote(srv.URL, "x"); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    _, _, err := testTools.PushJSONToRemote(srv.URL, "x")
+    if !errors.Is(err, ErrCircuitOpen) {
+        t.Errorf("expected ErrCircuitOpen, got %v", err)
+    }
+    if *calls != 2 {
+        t.Errorf("expected 2 calls to reach the server, got %d", *calls)
+    }
+
+    // after the timeout a single probe is allowed
+    now = now.Add(2 * time.Minute)
+    if err = breaker.Allow(host); err != nil {
+        t.Errorf("expected probe to be allowed, got %v", err)
+    }
+    if err = breaker.Allow(host); !errors.Is(err, ErrCircuitOpen) {
+        t.Errorf("expected concurrent probe to be refused, got %v", err)
+    }
+    breaker.Record(host, true)
+    if err = breaker.Allow(host); err != nil {
+        t.Errorf("expected circuit to be closed, got %v", err)
+    }
+}
+
+func TestRetryAfter(t *testing.T) {
+    now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    response := &http.Response{Header: http.Header{"Retry-After": {now.Add(5 * time.Second).Format(http.TimeFormat)}}}
+    if d, ok := retryAfter(response, now); !ok || d != 5*time.Second {
+        t.Errorf("expected 5s, got %s %v", d, ok)
+    }
+    response.Header.Set("Retry-After", "soon")
+    if _, ok := retryAfter(response, now); ok {
+        t.Error("expected invalid Retry-After to be ignored")
+    }
+}
+
+func mustHost(t *testing.T, uri string) string {
+    u, err := url.Parse(uri)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return u.Host
+}
@@ -0,0 +1,154 @@
+package toolkit
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// SizeUnits selects the units used by FormatSize
+type SizeUnits int
+
+const (
+    // SI formats sizes in powers of 1000: kB, MB, GB and so on
+    SI SizeUnits = iota
+    // IEC formats sizes in powers of 1024: KiB, MiB, GiB and so on
+    IEC
+)
+
+var sizeSymbols = map[SizeUnits][]string{
+    SI:  {"B", "kB", "MB", "GB", "TB", "PB", "EB"},
+    IEC: {"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"},
+}
+
+// sizeUnits maps the units accepted by ParseSize, in lower case, to their number of bytes.
+// Decimal units such as KB and MB are powers of 1000, binary units such as KiB, MiB or
+// the Kubernetes style Ki and Mi are powers of 1024
+var sizeUnits = map[string]int64{
+    "": 1, "b": 1,
+    "k": 1e3, "kb": 1e3, "ki": 1 << 10, "kib": 1 << 10,
+    "m": 1e6, "mb": 1e6, "mi": 1 << 20, "mib": 1 << 20,
+    "g": 1e9, "gb": 1e9, "gi": 1 << 30, "gib": 1 << 30,
+    "t": 1e12, "tb": 1e12, "ti": 1 << 40, "tib": 1 << 40,
+    "p": 1e15, "pb": 1e15, "pi": 1 << 50, "pib": 1 << 50,
+    "e": 1e18, "eb": 1e18, "ei": 1 << 60, "eib": 1 << 60,
+}
+
+// ParseSize parses a number of bytes with an optional unit, such as "512", "10MB" or "1.5 GiB".
+// Units are case-insensitive and fractions are rounded to the nearest byte. Sizes that do not
+// fit in an int64 are rejected
+func ParseSize(s string) (int64, error) {
+    text := strings.TrimSpace(s)
+    i := strings.IndexFunc(text, func(r rune) bool {
+        return (r < '0' || r > '9') && r != '.'
+    })
+    if i < 0 {
+        i = len(text)
+    }
+
+    number, unit := text[:i], strings.ToLower(strings.TrimSpace(text[i:]))
+    multiplier, ok := sizeUnits[unit]
+    if !ok || number == "" {
+        return 0, fmt.Errorf("invalid size %q", s)
+    }
+
+    // whole numbers are multiplied exactly, fractions go through a float64
+    if !strings.Contains(number, ".") {
+        n, err := strconv.ParseInt(number, 10, 64)
+        if numError, ok := err.(*strconv.NumError); ok && numError.Err == strconv.ErrRange {
+            return 0, fmt.Errorf("size %q is too large", s)
+        }
+        if err != nil {
+            return 0, fmt.Errorf("invalid size %q", s)
+        }
+        if n > math.MaxInt64/multiplier {
+            return 0, fmt.Errorf("size %q is too large", s)
+        }
+        return n * multiplier, nil
+    }
+
+    value, err := strconv.ParseFloat(number, 64)
+    if err != nil {
+        return 0, fmt.Errorf("invalid size %q", s)
+    }
+    bytes := math.Round(value * float64(multiplier))
+    if bytes >= math.MaxInt64 {
+        return 0, fmt.Errorf("size %q is too large", s)
+    }
+    return int64(bytes), nil
+}
+
+// FormatSize formats n bytes in the largest SI or IEC unit it reaches, with at most one
+// decimal, e.g. "512 B", "10 MB" or "1.5 GiB". The output does not depend on the locale
+func FormatSize(n int64, units SizeUnits) string {
+    symbols, ok := sizeSymbols[units]
+    if !ok {
+        symbols = sizeSymbols[SI]
+    }
+    base := 1000.0
+    if units == IEC {
+        base = 1024
+    }
+
+    sign := ""
+    value := float64(n)
+    if n < 0 {
+        sign, value = "-", -value
+    }
+
+    exp := 0
+    for value >= base && exp < len(symbols)-1 {
+        value /= base
+        exp++
+    }
+    // rounding may carry into the next unit, e.g. 999.96 kB is 1 MB
+    value = math.Round(value*10) / 10
+    if value >= base && exp < len(symbols)-1 {
+        value /= base
+        exp++
+    }
+
+    return sign + strconv.FormatFloat(value, 'f', -1, 64) + " " + symbols[exp]
+}
+
+// Size is a number of bytes. In configuration files and environment variables it can be
+// written as a number or as a human-readable string such as "10MB" or "1.5 GiB"
+type Size int
+
+// String formats s in SI units when it is a whole number of kilobytes, and in IEC units
+// otherwise, so that both 10 MB and 10 MiB limits read back the way they were written
+func (s Size) String() string {
+    if s%1000 == 0 {
+        return FormatSize(int64(s), SI)
+    }
+    return FormatSize(int64(s), IEC)
+}
+
+// UnmarshalText parses a human-readable size
+func (s *Size) UnmarshalText(text []byte) error {
+    n, err := ParseSize(string(text))
+    if err != nil {
+        return err
+    }
+    if n > math.MaxInt {
+        return fmt.Errorf("size %q is too large", text)
+    }
+    *s = Size(n)
+    return nil
+}
+
+// UnmarshalJSON accepts a number of bytes or a human-readable string
+func (s *Size) UnmarshalJSON(data []byte) error {
+    var text string
+    if err := json.Unmarshal(data, &text); err == nil {
+        return s.UnmarshalText([]byte(text))
+    }
+    var n int
+    if err := json.Unmarshal(data, &n); err != nil || n < 0 {
+        return fmt.Errorf("invalid size %s", data)
+    }
+    *s = Size(n)
+    return nil
+}
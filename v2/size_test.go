@@ -0,0 +1,127 @@
+package toolkit
+
+import (
+    "encoding/json"
+    "math"
+    "testing"
+)
+
+var parseSizeTests = []struct {
+    name          string
+    s             string
+    expected      int64
+    errorExpected bool
+}{
+    {name: "bytes", s: "512", expected: 512},
+    {name: "bytes with unit", s: "512B", expected: 512},
+    {name: "kilobytes", s: "10kB", expected: 10_000},
+    {name: "kibibytes", s: "10KiB", expected: 10 * 1024},
+    {name: "megabytes", s: "10MB", expected: 10_000_000},
+    {name: "mebibytes with space", s: "10 MiB", expected: 10 * 1024 * 1024},
+    {name: "kubernetes style", s: "64Mi", expected: 64 * 1024 * 1024},
+    {name: "lower case", s: "1gb", expected: 1_000_000_000},
+    {name: "short unit", s: "2k", expected: 2000},
+    {name: "fraction", s: "1.5GiB", expected: 1536 * 1024 * 1024},
+    {name: "decimal fraction", s: "2.5 TB", expected: 2_500_000_000_000},
+    {name: "rounded fraction", s: "1.0005kB", expected: 1001},
+    {name: "padded", s: " 64 KB ", expected: 64_000},
+    {name: "largest", s: "9223372036854775807", expected: math.MaxInt64},
+    {name: "exbibytes", s: "7EiB", expected: 7 << 60},
+    {name: "unknown unit", s: "10XB", errorExpected: true},
+    {name: "exponent", s: "1e3", errorExpected: true},
+    {name: "negative", s: "-1MB", errorExpected: true},
+    {name: "no number", s: "MB", errorExpected: true},
+    {name: "lone dot", s: ".MB", errorExpected: true},
+    {name: "empty", s: "", errorExpected: true},
+    {name: "too many digits", s: "9223372036854775808", errorExpected: true},
+    {name: "multiplication overflow", s: "8EiB", errorExpected: true},
+    {name: "fraction overflow", s: "9.5EB", errorExpected: true},
+}
+
+func TestParseSize(t *testing.T) {
+    for _, test := range parseSizeTests {
+        n, err := ParseSize(test.s)
+        if err == nil && test.errorExpected {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+        if err != nil && !test.errorExpected {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        if err == nil && n != test.expected {
+            t.Errorf("%s: expected %d, got %d", test.name, test.expected, n)
+        }
+    }
+}
+
+var formatSizeTests = []struct {
+    name     string
+    n        int64
+    units    SizeUnits
+    expected string
+}{
+    {name: "zero", n: 0, units: SI, expected: "0 B"},
+    {name: "bytes", n: 999, units: SI, expected: "999 B"},
+    {name: "kilobyte", n: 1000, units: SI, expected: "1 kB"},
+    {name: "kibibyte bytes", n: 1000, units: IEC, expected: "1000 B"},
+    {name: "kibibyte", n: 1024, units: IEC, expected: "1 KiB"},
+    {name: "megabytes", n: 10_000_000, units: SI, expected: "10 MB"},
+    {name: "mebibytes", n: 10 << 20, units: IEC, expected: "10 MiB"},
+    {name: "mebibytes in SI", n: 10 << 20, units: SI, expected: "10.5 MB"},
+    {name: "fraction", n: 1536 << 20, units: IEC, expected: "1.5 GiB"},
+    {name: "rounding carries", n: 999_960, units: SI, expected: "1 MB"},
+    {name: "negative", n: -1500, units: SI, expected: "-1.5 kB"},
+    {name: "largest", n: math.MaxInt64, units: SI, expected: "9.2 EB"},
+    {name: "largest IEC", n: math.MaxInt64, units: IEC, expected: "8 EiB"},
+    {name: "smallest", n: math.MinInt64, units: IEC, expected: "-8 EiB"},
+    {name: "unknown units", n: 2000, units: SizeUnits(9), expected: "2 kB"},
+}
+
+func TestFormatSize(t *testing.T) {
+    for _, test := range formatSizeTests {
+        if s := FormatSize(test.n, test.units); s != test.expected {
+            t.Errorf("%s: expected %q, got %q", test.name, test.expected, s)
+        }
+    }
+}
+
+func TestFormatSize_RoundTrip(t *testing.T) {
+    for _, n := range []int64{0, 1, 512, 1000, 1024, 10_000_000, 10 << 20, 1536 << 20, 7 << 60} {
+        for _, units := range []SizeUnits{SI, IEC} {
+            s := FormatSize(n, units)
+            parsed, err := ParseSize(s)
+            if err != nil {
+                t.Errorf("%d: %s does not parse: %s", n, s, err)
+                continue
+            }
+            // at most one decimal is kept, so allow for 5% of rounding
+            if math.Abs(float64(parsed-n)) > float64(n)/20 {
+                t.Errorf("%d: %s parses as %d", n, s, parsed)
+            }
+        }
+    }
+}
+
+func TestSize(t *testing.T) {
+    if s := Size(10_000_000).String(); s != "10 MB" {
+        t.Errorf("expected 10 MB, got %s", s)
+    }
+    if s := Size(DefaultMaxFileSize).String(); s != "1 GiB" {
+        t.Errorf("expected 1 GiB, got %s", s)
+    }
+
+    var sizes struct {
+        Text   Size `json:"text"`
+        Number Size `json:"number"`
+    }
+    if err := json.Unmarshal([]byte(`{"text": "1.5 MiB", "number": 2048}`), &sizes); err != nil {
+        t.Fatal(err)
+    }
+    if sizes.Text != 1536*1024 || sizes.Number != 2048 {
+        t.Errorf("unexpected sizes %+v", sizes)
+    }
+    for _, data := range []string{`{"number": -1}`, `{"number": 1.5}`, `{"text": "lots"}`, `{"text": true}`} {
+        if err := json.Unmarshal([]byte(data), &sizes); err == nil {
+            t.Errorf("%s: error expected, but none received", data)
+        }
+    }
+}
@@ -0,0 +1,300 @@
+package toolkit
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// SlugOptions configures Slugify
+type SlugOptions struct {
+    // Separator joins the words of the slug, "-" if empty
+    Separator string
+    // MaxLength limits the length of the slug in bytes, cutting it after the last word which
+    // fits; a first word longer than MaxLength is cut. No limit if zero
+    MaxLength int
+    // StopWords are left out of the slug, unless it would then be empty. They are compared
+    // with the transliterated words, in lower case
+    StopWords []string
+    // German transliterates ä, ö and ü to ae, oe and ue rather than a, o and u
+    German bool
+}
+
+// Slugify creates slug from a string. Accented Latin letters are folded to ASCII, and
+// Cyrillic and Greek letters transliterated; other characters separate words.
+// The string is scanned once, and without MaxLength or StopWords the slug is its only allocation
+func (t *Tools) Slugify(s string, options ...SlugOptions) (string, error) {
+    var opts SlugOptions
+    if len(options) > 0 {
+        opts = options[0]
+    }
+    if opts.MaxLength > 0 || len(opts.StopWords) > 0 {
+        words, err := slugWords(s, opts)
+        if err != nil {
+            return "", err
+        }
+        return joinWords(words, opts.separator(), opts.MaxLength), nil
+    }
+
+    if s == "" {
+        return "", errors.New("empty string is not permitted")
+    }
+    var b strings.Builder
+    b.Grow(len(s))
+    writeSlug(&b, s, opts.separator(), opts.German)
+    if b.Len() == 0 {
+        return "", errors.New("after removing characters, slug is zero length")
+    }
+    return b.String(), nil
+}
+
+func (o SlugOptions) separator() string {
+    if o.Separator == "" {
+        return "-"
+    }
+    return o.Separator
+}
+
+// slugWords returns the words making the slug of s, before they are joined
+func slugWords(s string, opts SlugOptions) ([]string, error) {
+    if s == "" {
+        return nil, errors.New("empty string is not permitted")
+    }
+
+    var b strings.Builder
+    b.Grow(len(s))
+    writeSlug(&b, s, " ", opts.German)
+    words := removeStopWords(strings.Fields(b.String()), opts.StopWords)
+    if len(words) == 0 {
+        return nil, errors.New("after removing characters, slug is zero length")
+    }
+    return words, nil
+}
+
+// writeSlug writes the lower case ASCII letters and digits of s to b, transliterating other
+// letters, and writes separator once between runs of the remaining characters
+func writeSlug(b *strings.Builder, s, separator string, german bool) {
+    pending := false
+    write := func(word string) {
+        if pending {
+            b.WriteString(separator)
+            pending = false
+        }
+        b.WriteString(word)
+    }
+
+    for i := 0; i < len(s); {
+        c := s[i]
+        if c < utf8.RuneSelf {
+            i++
+        } else {
+            r, size := utf8.DecodeRuneInString(s[i:])
+            i += size
+            if r = unicode.ToLower(r); r >= utf8.RuneSelf {
+                ascii, ok := "", false
+                if german {
+                    ascii, ok = germanTransliterations[r]
+                }
+                if !ok {
+                    ascii, ok = transliterations[r]
+                }
+                if ok {
+                    // letters such as the Cyrillic soft sign have no transliteration, but do not separate words
+                    if ascii != "" {
+                        write(ascii)
+                    }
+                } else {
+                    pending = b.Len() > 0
+                }
+                continue
+            }
+            // a few letters, such as the Kelvin sign, lower case to ASCII
+            c = byte(r)
+        }
+
+        if 'A' <= c && c <= 'Z' {
+            c += 'a' - 'A'
+        }
+        if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+            if pending {
+                b.WriteString(separator)
+                pending = false
+            }
+            b.WriteByte(c)
+        } else {
+            pending = b.Len() > 0
+        }
+    }
+}
+
+// ErrSlugUnavailable is returned by UniqueSlug when every attempted slug already exists
+var ErrSlugUnavailable = errors.New("no unique slug available")
+
+// SlugSuffix selects how UniqueSlug makes slugs unique
+type SlugSuffix int
+
+const (
+    // SlugSuffixCounter appends 2, 3 and so on: my-post, my-post-2, my-post-3
+    SlugSuffixCounter SlugSuffix = iota
+    // SlugSuffixRandom appends random lower case letters and digits: my-post, my-post-k3x9q2
+    SlugSuffixRandom
+)
+
+// slugSuffixAlphabet is the alphabet of random slug suffixes
+const slugSuffixAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// UniqueSlugOptions configures UniqueSlug
+type UniqueSlugOptions struct {
+    SlugOptions
+    Suffix SlugSuffix
+    // SuffixLength is the length of random suffixes, 6 if zero
+    SuffixLength int
+    // MaxAttempts is the number of slugs tried, including the one without suffix; 100 if zero
+    MaxAttempts int
+}
+
+// UniqueSlug returns the slug of s, made unique with a suffix when exists reports that it is
+// already taken. With SlugOptions.MaxLength, words are dropped to leave room for the suffix.
+// It stops with ctx.Err() when ctx is done, with the error of exists if it fails, and with
+// ErrSlugUnavailable after MaxAttempts slugs were taken
+func (t *Tools) UniqueSlug(ctx context.Context, s string, exists func(slug string) (bool, error), options ...UniqueSlugOptions) (string, error) {
+    var opts UniqueSlugOptions
+    if len(options) > 0 {
+        opts = options[0]
+    }
+    maxAttempts := opts.MaxAttempts
+    if maxAttempts <= 0 {
+        maxAttempts = 100
+    }
+    suffixLength := opts.SuffixLength
+    if suffixLength <= 0 {
+        suffixLength = 6
+    }
+
+    words, err := slugWords(s, opts.SlugOptions)
+    if err != nil {
+        return "", err
+    }
+    separator := opts.separator()
+
+    for attempt := 1; attempt <= maxAttempts; attempt++ {
+        if err = ctx.Err(); err != nil {
+            return "", err
+        }
+
+        var suffix string
+        if attempt > 1 {
+            switch opts.Suffix {
+            case SlugSuffixCounter:
+                suffix = strconv.Itoa(attempt)
+            case SlugSuffixRandom:
+                if suffix, err = t.RandomStringFrom(suffixLength, slugSuffixAlphabet); err != nil {
+                    return "", err
+                }
+            default:
+                return "", fmt.Errorf("unknown slug suffix %d", opts.Suffix)
+            }
+            suffix = separator + suffix
+        }
+
+        maxLength := opts.MaxLength
+        if maxLength > 0 {
+            if maxLength -= len(suffix); maxLength <= 0 {
+                return "", fmt.Errorf("%w: suffix %q does not fit in %d characters", ErrSlugUnavailable, suffix, opts.MaxLength)
+            }
+        }
+        slug := joinWords(words, separator, maxLength) + suffix
+
+        taken, err := exists(slug)
+        if err != nil {
+            return "", err
+        }
+        if !taken {
+            return slug, nil
+        }
+    }
+    return "", fmt.Errorf("%w after %d attempts", ErrSlugUnavailable, maxAttempts)
+}
+
+// removeStopWords returns words without stopWords, or words if they are all stop words
+func removeStopWords(words, stopWords []string) []string {
+    if len(stopWords) == 0 {
+        return words
+    }
+
+    kept := make([]string, 0, len(words))
+    for _, word := range words {
+        stop := false
+        for _, stopWord := range stopWords {
+            if strings.EqualFold(word, stopWord) {
+                stop = true
+                break
+            }
+        }
+        if !stop {
+            kept = append(kept, word)
+        }
+    }
+    if len(kept) == 0 {
+        return words
+    }
+    return kept
+}
+
+// joinWords joins words with separator, keeping as many words as fit in maxLength
+func joinWords(words []string, separator string, maxLength int) string {
+    if maxLength <= 0 {
+        return strings.Join(words, separator)
+    }
+    if len(words[0]) >= maxLength {
+        return words[0][:maxLength]
+    }
+
+    var b strings.Builder
+    b.Grow(maxLength)
+    b.WriteString(words[0])
+    for _, word := range words[1:] {
+        if b.Len()+len(separator)+len(word) > maxLength {
+            break
+        }
+        b.WriteString(separator)
+        b.WriteString(word)
+    }
+    return b.String()
+}
+
+// germanTransliterations take precedence over transliterations when SlugOptions.German is set
+var germanTransliterations = map[rune]string{'ä': "ae", 'ö': "oe", 'ü': "ue"}
+
+// transliterations maps lower case letters to ASCII
+var transliterations = map[rune]string{}
+
+func init() {
+    for letters, ascii := range map[string]string{
+        // Latin-1 Supplement and Latin Extended-A
+        "àáâãäåāăą": "a", "æ": "ae", "çćĉċč": "c", "ďđð": "d", "èéêëēĕėęě": "e",
+        "ĝğġģ": "g", "ĥħ": "h", "ìíîïĩīĭįı": "i", "ĳ": "ij", "ĵ": "j", "ķĸ": "k",
+        "ĺļľŀł": "l", "ñńņňŉŋ": "n", "òóôõöøōŏő": "o", "œ": "oe", "ŕŗř": "r",
+        "śŝşšș": "s", "ß": "ss", "ţťŧț": "t", "þ": "th", "ùúûüũūŭůűų": "u",
+        "ŵ": "w", "ýÿŷ": "y", "źżž": "z",
+        // Greek, including letters with tonos and dialytika
+        "αά": "a", "β": "v", "γ": "g", "δ": "d", "εέ": "e", "ζ": "z", "ηήιίϊΐ": "i",
+        "θ": "th", "κ": "k", "λ": "l", "μ": "m", "ν": "n", "ξ": "x", "οόωώ": "o",
+        "π": "p", "ρ": "r", "σς": "s", "τ": "t", "υύϋΰ": "y", "φ": "f", "χ": "ch", "ψ": "ps",
+        // Cyrillic: Russian, Ukrainian, Belarusian, Serbian and Macedonian letters
+        "а": "a", "б": "b", "в": "v", "гґ": "g", "д": "d", "её": "e", "ж": "zh", "з": "z",
+        "иі": "i", "й": "y", "ј": "j", "к": "k", "л": "l", "м": "m", "н": "n", "о": "o",
+        "п": "p", "р": "r", "с": "s", "т": "t", "уў": "u", "ф": "f", "х": "kh", "ц": "ts",
+        "ч": "ch", "ш": "sh", "щ": "shch", "ы": "y", "э": "e", "ю": "yu", "я": "ya",
+        "ъь": "", "є": "ye", "ї": "yi", "ђ": "dj", "ћ": "c", "љ": "lj", "њ": "nj",
+        "џѕ": "dz", "ѓ": "gj", "ќ": "kj",
+    } {
+        for _, r := range letters {
+            transliterations[r] = ascii
+        }
+    }
+}
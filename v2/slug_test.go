@@ -0,0 +1,286 @@
+package toolkit
+
+import (
+    "context"
+    "errors"
+    "regexp"
+    "strings"
+    "testing"
+    "unicode/utf8"
+)
+
+var transliterationTests = []struct {
+    name     string
+    s        string
+    options  SlugOptions
+    expected string
+}{
+    {name: "french", s: "Crème Brûlée", expected: "creme-brulee"},
+    {name: "spanish", s: "¿Dónde está el Niño?", expected: "donde-esta-el-nino"},
+    {name: "polish", s: "Zażółć gęślą jaźń", expected: "zazolc-gesla-jazn"},
+    {name: "czech", s: "Příliš žluťoučký kůň", expected: "prilis-zlutoucky-kun"},
+    {name: "nordic", s: "Ærø Smørrebrød Þórr", expected: "aero-smorrebrod-thorr"},
+    {name: "turkish", s: "İstanbul Şehri Ağaç", expected: "istanbul-sehri-agac"},
+    {name: "german sharp s", s: "Straße", expected: "strasse"},
+    {name: "german capital sharp s", s: "GROẞE", expected: "grosse"},
+    {name: "umlauts", s: "Müller Öl Ärger", expected: "muller-ol-arger"},
+    {name: "german umlauts", s: "Müller Öl Ärger", options: SlugOptions{German: true}, expected: "mueller-oel-aerger"},
+    {name: "russian", s: "Привет, мир! Щука и ёж", expected: "privet-mir-shchuka-i-ezh"},
+    {name: "ukrainian", s: "Їжак Єнот Ґанок", expected: "yizhak-yenot-ganok"},
+    {name: "serbian", s: "Ђорђе Љубав Њива Џеп", expected: "djordje-ljubav-njiva-dzep"},
+    {name: "hard and soft signs", s: "Объявление Соль", expected: "obyavlenie-sol"},
+    {name: "greek", s: "Καλημέρα κόσμε", expected: "kalimera-kosme"},
+    {name: "greek final sigma and digraphs", s: "Ψυχή Θεός Χάος", expected: "psychi-theos-chaos"},
+    {name: "mixed scripts", s: "Москва & Αθήνα & Zürich", expected: "moskva-athina-zurich"},
+    {name: "untransliterated", s: "東京 Tokyo", expected: "tokyo"},
+}
+
+func TestTools_Slugify_Transliteration(t *testing.T) {
+    var testTools Tools
+
+    for _, test := range transliterationTests {
+        slug, err := testTools.Slugify(test.s, test.options)
+        if err != nil {
+            t.Errorf("%s: error received when none expected: %s", test.name, err.Error())
+            continue
+        }
+        if slug != test.expected {
+            t.Errorf("%s: wrong slug returned; expected: %s, received: %s", test.name, test.expected, slug)
+        }
+    }
+}
+
+var slugOptionsTests = []struct {
+    name     string
+    s        string
+    options  SlugOptions
+    expected string
+}{
+    {name: "underscore", s: "Hello World", options: SlugOptions{Separator: "_"}, expected: "hello_world"},
+    {name: "dot", s: "Hello World", options: SlugOptions{Separator: "."}, expected: "hello.world"},
+    {name: "max length on word boundary", s: "The quick brown fox jumps", options: SlugOptions{MaxLength: 17}, expected: "the-quick-brown"},
+    {name: "max length exact", s: "The quick brown fox", options: SlugOptions{MaxLength: 15}, expected: "the-quick-brown"},
+    {name: "max length longer than slug", s: "The quick brown fox", options: SlugOptions{MaxLength: 100}, expected: "the-quick-brown-fox"},
+    {name: "max length cuts long first word", s: "Supercalifragilistic words", options: SlugOptions{MaxLength: 10}, expected: "supercalif"},
+    {name: "max length with separator", s: "ab cd ef", options: SlugOptions{MaxLength: 6, Separator: "--"}, expected: "ab--cd"},
+    {name: "stop words", s: "The Lord of the Rings", options: SlugOptions{StopWords: []string{"the", "of"}}, expected: "lord-rings"},
+    {name: "stop words are case insensitive", s: "A tale OF two cities", options: SlugOptions{StopWords: []string{"A", "of"}}, expected: "tale-two-cities"},
+    {name: "only stop words", s: "The Of", options: SlugOptions{StopWords: []string{"the", "of"}}, expected: "the-of"},
+    {name: "stop words before max length", s: "The Lord of the Rings", options: SlugOptions{StopWords: []string{"the", "of"}, MaxLength: 9}, expected: "lord"},
+    {name: "transliterated stop words", s: "Über die Brücke", options: SlugOptions{StopWords: []string{"die"}, German: true}, expected: "ueber-bruecke"},
+}
+
+func TestTools_Slugify_Options(t *testing.T) {
+    var testTools Tools
+
+    for _, test := range slugOptionsTests {
+        slug, err := testTools.Slugify(test.s, test.options)
+        if err != nil {
+            t.Errorf("%s: error received when none expected: %s", test.name, err.Error())
+            continue
+        }
+        if slug != test.expected {
+            t.Errorf("%s: wrong slug returned; expected: %s, received: %s", test.name, test.expected, slug)
+        }
+    }
+}
+
+// takenSlugs returns an exists callback reporting the given slugs as taken, and recording the checked ones
+func takenSlugs(checked *[]string, taken ...string) func(string) (bool, error) {
+    return func(slug string) (bool, error) {
+        *checked = append(*checked, slug)
+        for _, s := range taken {
+            if s == slug {
+                return true, nil
+            }
+        }
+        return false, nil
+    }
+}
+
+var uniqueSlugTests = []struct {
+    name          string
+    taken         []string
+    options       UniqueSlugOptions
+    expected      string
+    attempts      int
+    errorExpected bool
+}{
+    {name: "free", taken: nil, expected: "my-post", attempts: 1},
+    {name: "counter", taken: []string{"my-post", "my-post-2"}, expected: "my-post-3", attempts: 3},
+    {name: "counter with separator", taken: []string{"my_post"}, options: UniqueSlugOptions{SlugOptions: SlugOptions{Separator: "_"}}, expected: "my_post_2", attempts: 2},
+    {name: "counter with max length", taken: []string{"my-post"}, options: UniqueSlugOptions{SlugOptions: SlugOptions{MaxLength: 8}}, expected: "my-2", attempts: 2},
+    {name: "suffix too long", taken: []string{"my"}, options: UniqueSlugOptions{SlugOptions: SlugOptions{MaxLength: 2}}, attempts: 1, errorExpected: true},
+    {name: "exhausted", taken: []string{"my-post", "my-post-2", "my-post-3"}, options: UniqueSlugOptions{MaxAttempts: 3}, attempts: 3, errorExpected: true},
+}
+
+func TestTools_UniqueSlug(t *testing.T) {
+    var testTools Tools
+
+    for _, test := range uniqueSlugTests {
+        var checked []string
+        slug, err := testTools.UniqueSlug(context.Background(), "My Post", takenSlugs(&checked, test.taken...), test.options)
+        if err == nil && test.errorExpected {
+            t.Errorf("%s: error expected, but none received", test.name)
+        }
+        if err != nil && !test.errorExpected {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+        if err != nil && test.errorExpected && !errors.Is(err, ErrSlugUnavailable) {
+            t.Errorf("%s: error does not wrap ErrSlugUnavailable: %v", test.name, err)
+        }
+        if slug != test.expected {
+            t.Errorf("%s: expected %q, received %q", test.name, test.expected, slug)
+        }
+        if len(checked) != test.attempts {
+            t.Errorf("%s: expected %d attempts, got %v", test.name, test.attempts, checked)
+        }
+    }
+}
+
+func TestTools_UniqueSlug_Random(t *testing.T) {
+    var testTools Tools
+
+    var checked []string
+    options := UniqueSlugOptions{Suffix: SlugSuffixRandom, SuffixLength: 4}
+    slug, err := testTools.UniqueSlug(context.Background(), "My Post", takenSlugs(&checked, "my-post"), options)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(slug, "my-post-") || len(slug) != len("my-post-")+4 || strings.Trim(slug[8:], slugSuffixAlphabet) != "" {
+        t.Errorf("unexpected slug %q", slug)
+    }
+}
+
+func TestTools_UniqueSlug_Errors(t *testing.T) {
+    var testTools Tools
+
+    failure := errors.New("database is down")
+    _, err := testTools.UniqueSlug(context.Background(), "My Post", func(string) (bool, error) { return false, failure })
+    if !errors.Is(err, failure) {
+        t.Errorf("expected the error of exists, got %v", err)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    calls := 0
+    _, err = testTools.UniqueSlug(ctx, "My Post", func(string) (bool, error) {
+        calls++
+        cancel()
+        return true, nil
+    })
+    if !errors.Is(err, context.Canceled) || calls != 1 {
+        t.Errorf("expected cancellation after 1 call, got %v after %d", err, calls)
+    }
+
+    if _, err = testTools.UniqueSlug(context.Background(), "こんにちは", takenSlugs(new([]string))); err == nil {
+        t.Error("empty slug: error expected, but none received")
+    }
+}
+
+// slugifyRegexp is the previous, regular expression based, implementation of Slugify,
+// whose output for ASCII strings Slugify must keep
+func slugifyRegexp(s string) (string, error) {
+    if s == "" {
+        return "", errors.New("empty string is not permitted")
+    }
+
+    re := regexp.MustCompile(`[^a-z\d]+`)
+    slug := strings.Trim(re.ReplaceAllString(strings.ToLower(s), "-"), "-")
+    if len(slug) == 0 {
+        return "", errors.New("after removing characters, slug is zero length")
+    }
+
+    return slug, nil
+}
+
+func FuzzSlugify(f *testing.F) {
+    for _, test := range slugTests {
+        f.Add(test.s)
+    }
+    for _, test := range transliterationTests {
+        f.Add(test.s)
+    }
+    f.Add("--Hello,  World!--")
+    f.Add("\x00\xff invalid utf-8 \xc3")
+
+    var testTools Tools
+    f.Fuzz(func(t *testing.T, s string) {
+        slug, err := testTools.Slugify(s)
+
+        ascii := true
+        for i := 0; i < len(s); i++ {
+            if s[i] >= utf8.RuneSelf {
+                ascii = false
+                break
+            }
+        }
+        if ascii {
+            expected, expectedErr := slugifyRegexp(s)
+            if slug != expected || (err == nil) != (expectedErr == nil) {
+                t.Fatalf("%q: expected %q (%v), got %q (%v)", s, expected, expectedErr, slug, err)
+            }
+            return
+        }
+
+        if err != nil {
+            return
+        }
+        if strings.Trim(slug, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" ||
+            strings.HasPrefix(slug, "-") || strings.HasSuffix(slug, "-") || strings.Contains(slug, "--") {
+            t.Fatalf("%q: malformed slug %q", s, slug)
+        }
+
+        // the words are the same whatever the options
+        words, _ := testTools.Slugify(s, SlugOptions{Separator: "_", MaxLength: len(slug)})
+        if strings.ReplaceAll(words, "_", "-") != slug {
+            t.Fatalf("%q: %q differs from %q", s, words, slug)
+        }
+    })
+}
+
+func TestTools_Slugify_Allocations(t *testing.T) {
+    var testTools Tools
+
+    for _, s := range []string{"Now is the time for all GOOD men!!! + fish & such *()^13", "Crème Brûlée à la Москва"} {
+        allocs := testing.AllocsPerRun(100, func() {
+            _, _ = testTools.Slugify(s)
+        })
+        if allocs > 1 {
+            t.Errorf("%q: expected 1 allocation, got %.0f", s, allocs)
+        }
+    }
+}
+
+const slugBenchmarkTitle = "Apple iPhone 15 Pro Max (256 GB) - Natural Titanium, Unlocked!"
+
+func BenchmarkSlugify(b *testing.B) {
+    var testTools Tools
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        _, _ = testTools.Slugify(slugBenchmarkTitle)
+    }
+}
+
+func BenchmarkSlugify_Unicode(b *testing.B) {
+    var testTools Tools
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        _, _ = testTools.Slugify("Crème Brûlée für Zürich – Пирожки с капустой")
+    }
+}
+
+func BenchmarkSlugify_Options(b *testing.B) {
+    var testTools Tools
+    options := SlugOptions{MaxLength: 40, StopWords: []string{"the", "a", "of"}}
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        _, _ = testTools.Slugify(slugBenchmarkTitle, options)
+    }
+}
+
+func BenchmarkSlugify_Regexp(b *testing.B) {
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        _, _ = slugifyRegexp(slugBenchmarkTitle)
+    }
+}
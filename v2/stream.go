@@ -0,0 +1,327 @@
+package toolkit
+
+import (
+    "bufio"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "reflect"
+)
+
+// StreamFormat selects how StreamJSON frames the elements it writes
+type StreamFormat int
+
+const (
+    // StreamArray writes the elements as a single JSON array
+    StreamArray StreamFormat = iota
+    // StreamNDJSON writes one JSON value per line (newline delimited JSON)
+    StreamNDJSON
+)
+
+// StreamErrorTrailer is the HTTP trailer set when a stream fails after the response has started
+const StreamErrorTrailer = "X-Stream-Error"
+
+// StreamIterator returns the next element of a stream. It returns ok false when the stream
+// is exhausted, or an error to abort it
+type StreamIterator func() (element interface{}, ok bool, err error)
+
+// StreamOptions configures StreamJSON
+type StreamOptions struct {
+    Format StreamFormat
+    // FlushEvery is the number of elements written between flushes, 100 if zero
+    FlushEvery int
+    Headers    http.Header
+}
+
+// StreamJSON writes the elements returned by next to the client as they are produced, without
+// holding the whole payload in memory, flushing every FlushEvery elements.
+// If next fails before anything has been written, an error response is sent with ErrorJSON.
+// If it fails mid-stream, the StreamErrorTrailer trailer is set, NDJSON streams get a final
+// JSONResponse line, and arrays are left unterminated so that clients cannot mistake a partial
+// result for a complete one. RedactServerErrors applies to both cases, and the error is
+// always returned so it can be logged
+func (t *Tools) StreamJSON(w http.ResponseWriter, status int, next StreamIterator, options ...StreamOptions) error {
+    var opts StreamOptions
+    if len(options) > 0 {
+        opts = options[0]
+    }
+    flushEvery := 100
+    if opts.FlushEvery > 0 {
+        flushEvery = opts.FlushEvery
+    }
+
+    var (
+        bw      *bufio.Writer
+        written int
+    )
+    flusher, _ := w.(http.Flusher)
+    flush := func() error {
+        if err := bw.Flush(); err != nil {
+            return err
+        }
+        if flusher != nil {
+            flusher.Flush()
+        }
+        return nil
+    }
+
+    for {
+        element, ok, err := next()
+        if err != nil {
+            if bw == nil {
+                _ = t.ErrorJSON(w, err, http.StatusInternalServerError)
+                return err
+            }
+            t.failStream(w, bw, opts.Format, err)
+            _ = flush()
+            return err
+        }
+
+        if bw == nil {
+            // the response starts with the first element, so that early failures can still
+            // be reported with a proper status code
+            for k, v := range opts.Headers {
+                w.Header()[k] = v
+            }
+            if opts.Format == StreamNDJSON {
+                w.Header().Set("Content-Type", "application/x-ndjson")
+            } else {
+                w.Header().Set("Content-Type", "application/json")
+            }
+            w.Header().Set("Trailer", StreamErrorTrailer)
+            w.WriteHeader(status)
+            bw = bufio.NewWriter(w)
+            if opts.Format == StreamArray {
+                if err = bw.WriteByte('['); err != nil {
+                    return err
+                }
+            }
+        }
+
+        if !ok {
+            break
+        }
+
+        out, err := json.Marshal(element)
+        if err != nil {
+            err = fmt.Errorf("error encoding element %d: %w", written, err)
+            t.failStream(w, bw, opts.Format, err)
+            _ = flush()
+            return err
+        }
+
+        if opts.Format == StreamNDJSON {
+            out = append(out, '\n')
+        } else if written > 0 {
+            if err = bw.WriteByte(','); err != nil {
+                return err
+            }
+        }
+        if _, err = bw.Write(out); err != nil {
+            return err
+        }
+
+        written++
+        if written%flushEvery == 0 {
+            if err = flush(); err != nil {
+                return err
+            }
+        }
+    }
+
+    if opts.Format == StreamArray {
+        if err := bw.WriteByte(']'); err != nil {
+            return err
+        }
+    }
+    return flush()
+}
+
+// failStream reports err to the client after the response has started, in the StreamErrorTrailer
+// trailer and, for NDJSON streams, a final JSONResponse line. With RedactServerErrors the client
+// only gets a generic message and a correlation ID, and err is logged
+func (t *Tools) failStream(w http.ResponseWriter, bw *bufio.Writer, format StreamFormat, err error) {
+    response := JSONResponse{Error: true, Message: err.Error()}
+    trailer := response.Message
+    if t.RedactServerErrors {
+        var problem *Problem
+        if errors.As(t.redactError(w, err, err, http.StatusInternalServerError), &problem) {
+            response.Message = problem.Error()
+            response.Data = problem.Extensions
+            trailer = fmt.Sprintf("%s (correlation ID %s)", response.Message, problem.Extensions["correlationId"])
+        }
+    }
+
+    w.Header().Set(StreamErrorTrailer, trailer)
+    if format == StreamNDJSON {
+        line, _ := json.Marshal(response)
+        _, _ = bw.Write(append(line, '\n'))
+    }
+}
+
+// StreamJSONChannel is like StreamJSON, but streams the values received from ch, which
+// may be a channel of any element type, until it is closed. Receiving a non-nil error
+// value from the channel aborts the stream
+func (t *Tools) StreamJSONChannel(w http.ResponseWriter, status int, ch interface{}, options ...StreamOptions) error {
+    v := reflect.ValueOf(ch)
+    if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+        return errors.New("StreamJSONChannel needs a receivable channel")
+    }
+
+    return t.StreamJSON(w, status, func() (interface{}, bool, error) {
+        x, ok := v.Recv()
+        if !ok {
+            return nil, false, nil
+        }
+        element := x.Interface()
+        if err, isErr := element.(error); isErr && err != nil {
+            return nil, false, err
+        }
+        return element, true, nil
+    }, options...)
+}
+
+// StreamElementError reports a failure while reading or handling one element of a JSON stream
+type StreamElementError struct {
+    Index int
+    Err   error
+}
+
+func (e *StreamElementError) Error() string {
+    return fmt.Sprintf("element %d: %s", e.Index, e.Err.Error())
+}
+
+func (e *StreamElementError) Unwrap() error {
+    return e.Err
+}
+
+// ReadJSONStream reads a request body holding either a JSON array or newline delimited JSON
+// (detected from the Content-Type, or from the first character of the body) one element
+// at a time. Each element is decoded into element, which must be a pointer and is reset to
+// its zero value first, and then fn is called with the element index.
+// MaxJSONSize limits the whole body and MaxJSONElementSize each element; AllowUnknownFields
+// and ValidateJSON are honored. Failures of a single element, including errors returned by fn,
+// are reported as *StreamElementError
+func (t *Tools) ReadJSONStream(w http.ResponseWriter, r *http.Request, element interface{}, fn func(index int) error) error {
+    target := reflect.ValueOf(element)
+    if target.Kind() != reflect.Pointer || target.IsNil() {
+        return errors.New("ReadJSONStream needs a non-nil pointer to decode elements into")
+    }
+
+    maxBytes := t.maxJSONSize()
+    maxElementBytes := t.maxJSONElementSize()
+
+    r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+    body := bufio.NewReader(r.Body)
+    limiter := &elementLimitReader{r: body, limit: int64(maxElementBytes)}
+    dec := json.NewDecoder(limiter)
+    if !t.AllowUnknownFields {
+        dec.DisallowUnknownFields()
+    }
+
+    isArray, err := isJSONArrayStream(r.Header.Get("Content-Type"), body)
+    if err != nil {
+        return jsonDecodeError(err, maxBytes)
+    }
+    if isArray {
+        if _, err = dec.Token(); err != nil {
+            return jsonDecodeError(err, maxBytes)
+        }
+    }
+
+    zero := reflect.Zero(target.Elem().Type())
+    tooLarge := fmt.Errorf("element must not be larger than %d bytes", maxElementBytes)
+    for index := 0; ; index++ {
+        limiter.start = dec.InputOffset()
+        if isArray && !dec.More() {
+            break
+        }
+
+        target.Elem().Set(zero)
+        err = dec.Decode(element)
+        if !isArray && errors.Is(err, io.EOF) {
+            break
+        }
+        if errors.Is(err, errJSONElementTooLarge) {
+            return &StreamElementError{Index: index, Err: tooLarge}
+        }
+        if err != nil {
+            return &StreamElementError{Index: index, Err: jsonDecodeError(err, maxBytes)}
+        }
+        // the limiter stops reading just past the limit, an element ending there is caught here
+        if size := dec.InputOffset() - limiter.start; size > int64(maxElementBytes) {
+            return &StreamElementError{Index: index, Err: tooLarge}
+        }
+        if t.ValidateJSON {
+            if err = t.Validate(element); err != nil {
+                return &StreamElementError{Index: index, Err: err}
+            }
+        }
+        if err = fn(index); err != nil {
+            return &StreamElementError{Index: index, Err: err}
+        }
+    }
+
+    if isArray {
+        limiter.start = dec.InputOffset()
+        if _, err = dec.Token(); err != nil {
+            return jsonDecodeError(err, maxBytes)
+        }
+        if _, err = dec.Token(); err != io.EOF {
+            return errors.New("body must contain only one JSON array")
+        }
+    }
+    return nil
+}
+
+// errJSONElementTooLarge is returned by elementLimitReader once an element outgrows its limit
+var errJSONElementTooLarge = errors.New("json element too large")
+
+// elementLimitReader feeds a json.Decoder, refusing to read more than limit bytes (plus one,
+// to tell an element of exactly limit bytes from a larger one) past start, the input offset
+// at which the current element begins. This stops an oversized element before it is read
+// into memory, rather than after it has been decoded
+type elementLimitReader struct {
+    r     io.Reader
+    limit int64
+    start int64
+    read  int64
+}
+
+func (l *elementLimitReader) Read(p []byte) (int, error) {
+    remaining := l.start + l.limit + 1 - l.read
+    if remaining <= 0 {
+        return 0, errJSONElementTooLarge
+    }
+    if int64(len(p)) > remaining {
+        p = p[:remaining]
+    }
+    n, err := l.r.Read(p)
+    l.read += int64(n)
+    return n, err
+}
+
+// isJSONArrayStream reports whether a stream body is a JSON array rather than newline
+// delimited JSON, peeking at the body when the content type does not tell
+func isJSONArrayStream(contentType string, body *bufio.Reader) (bool, error) {
+    mediaType, _, _ := mime.ParseMediaType(contentType)
+    switch mediaType {
+    case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+        return false, nil
+    }
+
+    for {
+        c, err := body.ReadByte()
+        if err != nil {
+            return false, err
+        }
+        switch c {
+        case ' ', '\t', '\r', '\n':
+            continue
+        }
+        return c == '[', body.UnreadByte()
+    }
+}
@@ -0,0 +1,330 @@
+package toolkit
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "io"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+// sliceIterator returns a StreamIterator over items, failing with err after failAfter items
+func sliceIterator(items []int, failAfter int, err error) StreamIterator {
+    i := 0
+    return func() (interface{}, bool, error) {
+        if err != nil && i == failAfter {
+            return nil, false, err
+        }
+        if i >= len(items) {
+            return nil, false, nil
+        }
+        i++
+        return map[string]int{"id": items[i-1]}, true, nil
+    }
+}
+
+var streamTests = []struct {
+    name          string
+    format        StreamFormat
+    items         []int
+    failAfter     int
+    err           error
+    expectedCode  int
+    expectedBody  string
+    expectedError string
+}{
+    {name: "array", format: StreamArray, items: []int{1, 2, 3}, expectedCode: http.StatusOK, expectedBody: `[{"id":1},{"id":2},{"id":3}]`},
+    {name: "empty array", format: StreamArray, expectedCode: http.StatusOK, expectedBody: `[]`},
+    {name: "ndjson", format: StreamNDJSON, items: []int{1, 2}, expectedCode: http.StatusOK, expectedBody: "{\"id\":1}\n{\"id\":2}\n"},
+    {
+        name: "array fails mid-stream", format: StreamArray, items: []int{1, 2, 3}, failAfter: 2, err: errors.New("db gone"),
+        expectedCode: http.StatusOK, expectedBody: `[{"id":1},{"id":2}`, expectedError: "db gone",
+    },
+    {
+        name: "ndjson fails mid-stream", format: StreamNDJSON, items: []int{1, 2}, failAfter: 1, err: errors.New("db gone"),
+        expectedCode: http.StatusOK, expectedBody: "{\"id\":1}\n{\"error\":true,\"message\":\"db gone\"}\n", expectedError: "db gone",
+    },
+    {
+        name: "fails before first element", format: StreamArray, items: []int{1}, failAfter: 0, err: errors.New("db gone"),
+        expectedCode: http.StatusInternalServerError, expectedBody: "{\"error\":true,\"message\":\"db gone\"}",
+    },
+}
+
+func TestTools_StreamJSON(t *testing.T) {
+    var testTools Tools
+
+    for _, test := range streamTests {
+        rr := httptest.NewRecorder()
+        err := testTools.StreamJSON(rr, http.StatusOK, sliceIterator(test.items, test.failAfter, test.err),
+            StreamOptions{Format: test.format, FlushEvery: 1})
+
+        if test.err != nil && !errors.Is(err, test.err) {
+            t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+        }
+        if test.err == nil && err != nil {
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        }
+
+        res := rr.Result()
+        if res.StatusCode != test.expectedCode {
+            t.Errorf("%s: expected status %d, got %d", test.name, test.expectedCode, res.StatusCode)
+        }
+        if body := rr.Body.String(); body != test.expectedBody {
+            t.Errorf("%s: expected body %q, got %q", test.name, test.expectedBody, body)
+        }
+        if got := res.Trailer.Get(StreamErrorTrailer); got != test.expectedError {
+            t.Errorf("%s: expected trailer %q, got %q", test.name, test.expectedError, got)
+        }
+        if test.expectedCode == http.StatusOK && !rr.Flushed {
+            t.Errorf("%s: expected response to be flushed", test.name)
+        }
+    }
+}
+
+func TestTools_StreamJSONEncodingError(t *testing.T) {
+    var testTools Tools
+    items := []interface{}{1, make(chan int)}
+    i := 0
+    next := func() (interface{}, bool, error) {
+        if i >= len(items) {
+            return nil, false, nil
+        }
+        i++
+        return items[i-1], true, nil
+    }
+
+    rr := httptest.NewRecorder()
+    err := testTools.StreamJSON(rr, http.StatusOK, next, StreamOptions{Format: StreamNDJSON})
+    var typeError *json.UnsupportedTypeError
+    if !errors.As(err, &typeError) {
+        t.Fatalf("expected an unsupported type error, got %v", err)
+    }
+
+    expected := "1\n{\"error\":true,\"message\":\"error encoding element 1: json: unsupported type: chan int\"}\n"
+    if body := rr.Body.String(); body != expected {
+        t.Errorf("expected body %q, got %q", expected, body)
+    }
+    if trailer := rr.Result().Trailer.Get(StreamErrorTrailer); trailer != err.Error() {
+        t.Errorf("expected trailer %q, got %q", err.Error(), trailer)
+    }
+}
+
+func TestTools_StreamJSONRedact(t *testing.T) {
+    var logs bytes.Buffer
+    testTools := Tools{
+        RedactServerErrors: true,
+        Logger:             slog.New(slog.NewJSONHandler(&logs, nil)),
+    }
+    secret := errors.New(`pq: relation "users" does not exist at /srv/app/db.go:42`)
+
+    for _, format := range []StreamFormat{StreamArray, StreamNDJSON} {
+        logs.Reset()
+        rr := httptest.NewRecorder()
+        err := testTools.StreamJSON(rr, http.StatusOK, sliceIterator([]int{1, 2}, 1, secret), StreamOptions{Format: format})
+        if !errors.Is(err, secret) {
+            t.Errorf("expected the original error to be returned, got %v", err)
+        }
+
+        var entry map[string]interface{}
+        if err = json.Unmarshal(logs.Bytes(), &entry); err != nil {
+            t.Fatal(err)
+        }
+        id, _ := entry["correlation_id"].(string)
+        if id == "" || entry["error"] != secret.Error() {
+            t.Errorf("unexpected log entry %v", entry)
+        }
+
+        trailer := rr.Result().Trailer.Get(StreamErrorTrailer)
+        if strings.Contains(trailer, "pq:") || strings.Contains(rr.Body.String(), "pq:") {
+            t.Errorf("internal error leaked to client: %q %q", trailer, rr.Body.String())
+        }
+        if trailer != "Internal Server Error (correlation ID "+id+")" {
+            t.Errorf("unexpected trailer %q", trailer)
+        }
+        if format == StreamNDJSON {
+            lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+            var payload JSONResponse
+            if err = json.Unmarshal([]byte(lines[len(lines)-1]), &payload); err != nil {
+                t.Fatal(err)
+            }
+            data, _ := payload.Data.(map[string]interface{})
+            if !payload.Error || payload.Message != "Internal Server Error" || data["correlationId"] != id {
+                t.Errorf("unexpected error line %+v", payload)
+            }
+        }
+    }
+}
+
+func TestTools_StreamJSONChannel(t *testing.T) {
+    var testTools Tools
+
+    ch := make(chan string)
+    go func() {
+        defer close(ch)
+        for _, s := range []string{"a", "b", "c"} {
+            ch <- s
+        }
+    }()
+
+    rr := httptest.NewRecorder()
+    err := testTools.StreamJSONChannel(rr, http.StatusOK, ch, StreamOptions{Format: StreamNDJSON})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+        t.Errorf("wrong content type %s", ct)
+    }
+
+    dec := json.NewDecoder(strings.NewReader(rr.Body.String()))
+    var received []string
+    for dec.More() {
+        var s string
+        if err = dec.Decode(&s); err != nil {
+            t.Fatal(err)
+        }
+        received = append(received, s)
+    }
+    if strings.Join(received, "") != "abc" {
+        t.Errorf("expected abc, got %v", received)
+    }
+
+    errCh := make(chan interface{}, 2)
+    errCh <- 1
+    errCh <- errors.New("boom")
+    if err = testTools.StreamJSONChannel(httptest.NewRecorder(), http.StatusOK, errCh); err == nil || err.Error() != "boom" {
+        t.Errorf("expected boom error, got %v", err)
+    }
+
+    if err = testTools.StreamJSONChannel(httptest.NewRecorder(), http.StatusOK, []int{1}); err == nil {
+        t.Error("expected error for non-channel source")
+    }
+}
+
+var readStreamTests = []struct {
+    name          string
+    contentType   string
+    body          string
+    maxSize       int
+    maxElement    int
+    allowUnknown  bool
+    expectedNames []string
+    failedIndex   int
+}{
+    {name: "array", body: `[{"name":"a"}, {"name":"b"}]`, expectedNames: []string{"a", "b"}, failedIndex: -1},
+    {name: "empty array", body: ` [ ] `, failedIndex: -1},
+    {name: "ndjson", body: "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", expectedNames: []string{"a", "b"}, failedIndex: -1},
+    {name: "ndjson content type", contentType: "application/x-ndjson", body: "{\"name\":\"a\"}\n", expectedNames: []string{"a"}, failedIndex: -1},
+    {name: "unknown field", body: `[{"name":"a"}, {"nme":"b"}]`, expectedNames: []string{"a"}, failedIndex: 1},
+    {name: "unknown field allowed", body: `[{"name":"a"}, {"nme":"b"}]`, allowUnknown: true, expectedNames: []string{"a", ""}, failedIndex: -1},
+    {name: "bad element", body: "{\"name\":\"a\"}\n{\"name\":1}\n", expectedNames: []string{"a"}, failedIndex: 1},
+    {name: "element too large", body: `[{"name":"a"}, {"name":"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}]`, maxElement: 20, expectedNames: []string{"a"}, failedIndex: 1},
+    {name: "ndjson element too large", body: "{\"name\":\"a\"}\n{\"name\":\"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb\"}\n", maxElement: 20, expectedNames: []string{"a"}, failedIndex: 1},
+    {name: "many elements under limit", body: `[{"name":"a"}, {"name":"b"}, {"name":"c"}, {"name":"d"}, {"name":"e"}]`, maxElement: 16, expectedNames: []string{"a", "b", "c", "d", "e"}, failedIndex: -1},
+    {name: "body too large", body: `[{"name":"a"}, {"name":"b"}, {"name":"c"}]`, maxSize: 30, expectedNames: []string{"a", "b"}, failedIndex: 2},
+}
+
+func TestTools_ReadJSONStream(t *testing.T) {
+    for _, test := range readStreamTests {
+        testTools := Tools{
+            MaxJSONSize:        test.maxSize,
+            MaxJSONElementSize: test.maxElement,
+            AllowUnknownFields: test.allowUnknown,
+        }
+
+        req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+        if test.contentType != "" {
+            req.Header.Set("Content-Type", test.contentType)
+        }
+
+        var (
+            element struct {
+                Name string `json:"name"`
+            }
+            names []string
+        )
+        err := testTools.ReadJSONStream(httptest.NewRecorder(), req, &element, func(index int) error {
+            if index != len(names) {
+                t.Errorf("%s: expected index %d, got %d", test.name, len(names), index)
+            }
+            names = append(names, element.Name)
+            return nil
+        })
+
+        var elementError *StreamElementError
+        switch {
+        case test.failedIndex < 0 && err != nil:
+            t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+        case test.failedIndex >= 0 && !errors.As(err, &elementError):
+            t.Errorf("%s: expected StreamElementError, got %v", test.name, err)
+        case test.failedIndex >= 0 && elementError.Index != test.failedIndex:
+            t.Errorf("%s: expected failure at element %d, got %d", test.name, test.failedIndex, elementError.Index)
+        }
+        if strings.Join(names, ",") != strings.Join(test.expectedNames, ",") {
+            t.Errorf("%s: expected elements %v, got %v", test.name, test.expectedNames, names)
+        }
+    }
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+    r io.Reader
+    n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+    n, err := c.r.Read(p)
+    c.n += n
+    return n, err
+}
+
+func TestTools_ReadJSONStreamElementNotRead(t *testing.T) {
+    testTools := Tools{MaxJSONSize: 1024 * 1024, MaxJSONElementSize: 10}
+    large := `["` + strings.Repeat("x", 500*1024) + `"]`
+    body := &countingReader{r: strings.NewReader("[1, " + large + "]")}
+    req, _ := http.NewRequest("POST", "/", body)
+
+    var element interface{}
+    calls := 0
+    err := testTools.ReadJSONStream(httptest.NewRecorder(), req, &element, func(index int) error {
+        calls++
+        return nil
+    })
+
+    var elementError *StreamElementError
+    if !errors.As(err, &elementError) || elementError.Index != 1 || !strings.Contains(err.Error(), "larger than 10 bytes") {
+        t.Fatalf("expected element 1 to be too large, got %v", err)
+    }
+    if calls != 1 {
+        t.Errorf("expected 1 element to be handled, got %d", calls)
+    }
+    // only the buffer in front of the decoder may have been filled
+    if body.n > 8*1024 {
+        t.Errorf("expected the oversized element not to be read, but %d bytes were", body.n)
+    }
+}
+
+func TestTools_ReadJSONStreamCallbackError(t *testing.T) {
+    var testTools Tools
+    req, _ := http.NewRequest("POST", "/", strings.NewReader(`[1, 2, 3]`))
+
+    stop := errors.New("stop")
+    var n int
+    err := testTools.ReadJSONStream(httptest.NewRecorder(), req, &n, func(index int) error {
+        if n == 2 {
+            return stop
+        }
+        return nil
+    })
+    if !errors.Is(err, stop) || !strings.HasPrefix(err.Error(), "element 1:") {
+        t.Errorf("expected stop error at element 1, got %v", err)
+    }
+
+    req, _ = http.NewRequest("POST", "/", strings.NewReader(``))
+    if err = testTools.ReadJSONStream(httptest.NewRecorder(), req, &n, func(int) error { return nil }); err == nil {
+        t.Error("expected error for empty body")
+    }
+}
@@ -0,0 +1,125 @@
+package toolkit
+
+import (
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "strings"
+)
+
+// ErrInvalidAPIKey is returned when an API key is malformed or its checksum does not match
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// API keys are made of a prefix, an underscore, apiKeySecretLength random alphanumeric
+// characters and apiKeyChecksumLength characters of checksum: tk_live_<32 random><6 checksum>
+const (
+    apiKeySecretLength   = 32
+    apiKeyChecksumLength = 6
+)
+
+// NewAPIKey returns a new API key starting with prefix, such as "tk_live". The prefix may hold
+// lower case letters, digits and underscores. The key ends with a CRC32 checksum, so that
+// mistyped or truncated keys are rejected by ParseAPIKey without a lookup.
+// Store only the form returned by HashAPIKey
+func (t *Tools) NewAPIKey(prefix string) (string, error) {
+    if err := checkAPIKeyPrefix(prefix); err != nil {
+        return "", err
+    }
+    secret, err := t.RandomStringFrom(apiKeySecretLength, AlphabetAlphanumeric)
+    if err != nil {
+        return "", err
+    }
+    key := prefix + "_" + secret
+    return key + apiKeyChecksum(key), nil
+}
+
+// ParseAPIKey checks the format and checksum of key and returns its prefix.
+// Errors wrap ErrInvalidAPIKey
+func ParseAPIKey(key string) (string, error) {
+    i := strings.LastIndexByte(key, '_')
+    if i < 0 || len(key)-i-1 != apiKeySecretLength+apiKeyChecksumLength {
+        return "", fmt.Errorf("%w: malformed key", ErrInvalidAPIKey)
+    }
+
+    prefix, body := key[:i], key[i+1:]
+    if checkAPIKeyPrefix(prefix) != nil || strings.Trim(body, AlphabetAlphanumeric) != "" {
+        return "", fmt.Errorf("%w: malformed key", ErrInvalidAPIKey)
+    }
+
+    checksum := key[len(key)-apiKeyChecksumLength:]
+    if subtle.ConstantTimeCompare([]byte(checksum), []byte(apiKeyChecksum(key[:len(key)-apiKeyChecksumLength]))) != 1 {
+        return "", fmt.Errorf("%w: checksum does not match", ErrInvalidAPIKey)
+    }
+    return prefix, nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 digest of key, the form in which keys are stored.
+// Keys hold enough entropy that a fast, unsalted hash is sufficient
+func HashAPIKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIKey reports, in constant time, whether key matches a hash made by HashAPIKey
+func VerifyAPIKey(key, hash string) bool {
+    expected, err := hex.DecodeString(hash)
+    if err != nil {
+        return false
+    }
+    sum := sha256.Sum256([]byte(key))
+    return subtle.ConstantTimeCompare(sum[:], expected) == 1
+}
+
+// CompareAPIKeys reports whether a and b are equal, in a time which depends neither on
+// their content nor on their length
+func CompareAPIKeys(a, b string) bool {
+    sumA, sumB := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+    return subtle.ConstantTimeCompare(sumA[:], sumB[:]) == 1
+}
+
+func checkAPIKeyPrefix(prefix string) error {
+    if prefix == "" || prefix[0] == '_' || prefix[len(prefix)-1] == '_' {
+        return fmt.Errorf("%w: prefix must not be empty, nor start or end with an underscore", ErrInvalidAPIKey)
+    }
+    if strings.Trim(prefix, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+        return fmt.Errorf("%w: prefix may only hold lower case letters, digits and underscores", ErrInvalidAPIKey)
+    }
+    return nil
+}
+
+// apiKeyChecksum returns the CRC32 of s in base62, padded to apiKeyChecksumLength characters
+func apiKeyChecksum(s string) string {
+    sum := crc32.ChecksumIEEE([]byte(s))
+
+    var buf [apiKeyChecksumLength]byte
+    for i := len(buf) - 1; i >= 0; i-- {
+        buf[i] = AlphabetAlphanumeric[sum%62]
+        sum /= 62
+    }
+    return string(buf[:])
+}
+
+// NewOTPCode returns a random numeric code of the given number of digits, between 4 and 12,
+// such as those sent by email or text message to confirm an address
+func (t *Tools) NewOTPCode(digits int) (string, error) {
+    if digits < 4 || digits > 12 {
+        return "", fmt.Errorf("otp codes must have between 4 and 12 digits, not %d", digits)
+    }
+    return t.RandomStringFrom(digits, AlphabetNumeric)
+}
+
+// ValidateOTPCode checks that code is made of the given number of digits
+func ValidateOTPCode(code string, digits int) error {
+    if len(code) != digits || strings.Trim(code, AlphabetNumeric) != "" {
+        return fmt.Errorf("code must be made of %d digits", digits)
+    }
+    return nil
+}
+
+// VerifyOTPCode reports, in constant time, whether code matches expected
+func VerifyOTPCode(code, expected string) bool {
+    return subtle.ConstantTimeCompare([]byte(code), []byte(expected)) == 1
+}
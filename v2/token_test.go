@@ -0,0 +1,123 @@
+package toolkit
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestTools_NewAPIKey(t *testing.T) {
+    var testTools Tools
+
+    key, err := testTools.NewAPIKey("tk_live")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(key, "tk_live_") || len(key) != len("tk_live_")+apiKeySecretLength+apiKeyChecksumLength {
+        t.Errorf("malformed key %s", key)
+    }
+
+    prefix, err := ParseAPIKey(key)
+    if err != nil || prefix != "tk_live" {
+        t.Errorf("unexpected prefix %q: %v", prefix, err)
+    }
+
+    other, _ := testTools.NewAPIKey("tk_live")
+    if other == key {
+        t.Error("keys are not random")
+    }
+
+    for _, prefix := range []string{"", "_tk", "tk_", "TK", "tk-live"} {
+        if _, err = testTools.NewAPIKey(prefix); err == nil {
+            t.Errorf("prefix %q: error expected, but none received", prefix)
+        }
+    }
+}
+
+func TestParseAPIKey(t *testing.T) {
+    var testTools Tools
+    key, _ := testTools.NewAPIKey("tk_test")
+
+    // change one character of the secret, keeping the checksum
+    typo := []byte(key)
+    if typo[10] == 'a' {
+        typo[10] = 'b'
+    } else {
+        typo[10] = 'a'
+    }
+
+    var tests = []struct {
+        name  string
+        input string
+    }{
+        {name: "typo", input: string(typo)},
+        {name: "truncated", input: key[:len(key)-1]},
+        {name: "extended", input: key + "a"},
+        {name: "no prefix", input: key[len("tk_test_"):]},
+        {name: "invalid character", input: key[:len(key)-7] + "-" + key[len(key)-6:]},
+        {name: "empty", input: ""},
+    }
+    for _, test := range tests {
+        _, err := ParseAPIKey(test.input)
+        if err == nil {
+            t.Errorf("%s: error expected, but none received", test.name)
+        } else if !errors.Is(err, ErrInvalidAPIKey) {
+            t.Errorf("%s: error does not wrap ErrInvalidAPIKey: %v", test.name, err)
+        }
+    }
+}
+
+func TestHashAPIKey(t *testing.T) {
+    var testTools Tools
+    key, _ := testTools.NewAPIKey("tk_live")
+    other, _ := testTools.NewAPIKey("tk_live")
+
+    hash := HashAPIKey(key)
+    if len(hash) != 64 || strings.Contains(hash, key) {
+        t.Errorf("unexpected hash %s", hash)
+    }
+    if !VerifyAPIKey(key, hash) {
+        t.Error("key does not match its hash")
+    }
+    if VerifyAPIKey(other, hash) {
+        t.Error("other key matches the hash")
+    }
+    if VerifyAPIKey(key, "not hex") {
+        t.Error("key matches a malformed hash")
+    }
+
+    if !CompareAPIKeys(key, key) || CompareAPIKeys(key, other) || CompareAPIKeys(key, key[:10]) {
+        t.Error("unexpected comparison result")
+    }
+}
+
+func TestTools_NewOTPCode(t *testing.T) {
+    var testTools Tools
+
+    for _, digits := range []int{4, 6, 8, 12} {
+        code, err := testTools.NewOTPCode(digits)
+        if err != nil {
+            t.Errorf("%d digits: %v", digits, err)
+            continue
+        }
+        if err = ValidateOTPCode(code, digits); err != nil {
+            t.Errorf("%d digits: %v", digits, err)
+        }
+    }
+
+    for _, digits := range []int{0, 3, 13} {
+        if _, err := testTools.NewOTPCode(digits); err == nil {
+            t.Errorf("%d digits: error expected, but none received", digits)
+        }
+    }
+
+    for _, code := range []string{"12345", "1234567", "12a456", ""} {
+        if ValidateOTPCode(code, 6) == nil {
+            t.Errorf("%q: error expected, but none received", code)
+        }
+    }
+
+    if !VerifyOTPCode("123456", "123456") || VerifyOTPCode("123456", "123457") || VerifyOTPCode("12345", "123456") {
+        t.Error("unexpected verification result")
+    }
+}
@@ -2,45 +2,92 @@ package toolkit
 
 import (
     "bytes"
-    "crypto/rand"
+    "context"
     "encoding/json"
     "errors"
     "fmt"
     "io"
-    "math/big"
+    "log/slog"
     "mime/multipart"
     "net/http"
     "os"
     "path/filepath"
-    "regexp"
     "strings"
 )
 
-const randomStringSource = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+"
-
 // Tools is a type used to instantiate this module. Any variable of this type will have access
-// to all the methods with the receiver *Tools
+// to all the methods with the receiver *Tools. The zero value is ready to use, with defaults
+// for every field; New additionally validates the configuration. Once in use, a Tools must not
+// be modified, and is then safe for concurrent use
 type Tools struct {
     MaxFileSize        int
     AllowedFileTypes   []string
     MaxJSONSize        int
     AllowUnknownFields bool
+    // MaxJSONElementSize limits the size of a single element read by ReadJSONStream, 1 MB if zero
+    MaxJSONElementSize int
+    // ValidateJSON makes ReadJSON run Validate on the decoded data
+    ValidateJSON bool
+    // Codecs used by ReadBody and WriteBody; DefaultCodecRegistry is used when nil
+    Codecs *CodecRegistry
+    // ErrorFormat selects the body written by ErrorJSON
+    ErrorFormat ErrorFormat
+    // ErrorRenderer, when set, replaces the body written by ErrorJSON
+    ErrorRenderer ErrorRenderer
+    // Errors maps errors to status codes for ErrorJSON calls without a status
+    Errors *ErrorRegistry
+    // Logger receives errors that are not sent to clients; slog.Default is used when nil
+    Logger *slog.Logger
+    // RedactServerErrors makes ErrorJSON replace 5xx errors by a generic message and a correlation ID
+    RedactServerErrors bool
+    // CorrelationIDHeader is the response header carrying correlation IDs, DefaultCorrelationIDHeader if empty
+    CorrelationIDHeader string
+    // Retry configures retries of PushJSONToRemote; calls are not retried when nil
+    Retry *RetryPolicy
+    // CircuitBreaker, when set, stops PushJSONToRemote from calling hosts which keep failing
+    CircuitBreaker *CircuitBreaker
+    // Authenticator adds credentials to every request sent by PushJSONToRemote
+    Authenticator Authenticator
+    // Compression, when set, gzips large request bodies sent by PushJSONToRemote
+    Compression *Compression
+    // Passwords configures HashPassword and CheckPasswordStrength; defaults are used when nil
+    Passwords *PasswordPolicy
 }
 
-// RandomString returns a string of random characters of length n, using randomStringSource
-// as a source of characters to generate random string
-func (t *Tools) RandomString(n int) string {
-    var (
-        p    *big.Int
-        x, y uint64
-    )
-    s, r := make([]rune, n), []rune(randomStringSource)
-    for i := range s {
-        p, _ = rand.Prime(rand.Reader, len(r))
-        x, y = p.Uint64(), uint64(len(r))
-        s[i] = r[x%y]
+// Defaults used when the corresponding fields of Tools are zero
+const (
+    DefaultMaxFileSize        = 1024 * 1024 * 1024 // 1 GiB
+    DefaultMaxJSONSize        = 1024 * 1024        // 1 MiB
+    DefaultMaxJSONElementSize = 1024 * 1024        // 1 MiB, or MaxJSONSize if that is smaller
+)
+
+func (t *Tools) maxFileSize() int {
+    if t.MaxFileSize > 0 {
+        return t.MaxFileSize
+    }
+    return DefaultMaxFileSize
+}
+
+func (t *Tools) maxJSONSize() int {
+    if t.MaxJSONSize > 0 {
+        return t.MaxJSONSize
     }
-    return string(s)
+    return DefaultMaxJSONSize
+}
+
+func (t *Tools) maxJSONElementSize() int {
+    if t.MaxJSONElementSize > 0 {
+        return t.MaxJSONElementSize
+    }
+    return min(DefaultMaxJSONElementSize, t.maxJSONSize())
+}
+
+// logger returns the configured logger, or the default one
+func (t *Tools) logger() *slog.Logger {
+    if t.Logger != nil {
+        return t.Logger
+    }
+    return slog.Default()
 }
 
 // UploadedFile is a type used to save information about an uploaded file
@@ -50,6 +97,17 @@ type UploadedFile struct {
     FileSize         int64
 }
 
+// FileTooLargeError is returned by UploadFiles when an uploaded file is larger than MaxFileSize
+type FileTooLargeError struct {
+    FileName string
+    Size     int64
+    Limit    int64
+}
+
+func (e *FileTooLargeError) Error() string {
+    return fmt.Sprintf("file exceeds %s limit", Size(e.Limit))
+}
+
 func (t *Tools) UploadFile(r *http.Request, uploadDir string, rename ...bool) (*UploadedFile, error) {
     renameFile := true
     if len(rename) > 0 {
@@ -74,23 +132,26 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
         uploadedFiles []*UploadedFile
     )
 
-    if t.MaxFileSize == 0 {
-        t.MaxFileSize = 1024 * 1024 * 1024 // 1 GB
-    }
-
     err := t.CreateDirIfNotExist(uploadDir)
     if err != nil {
         return nil, err
     }
 
-    err = r.ParseMultipartForm(int64(t.MaxFileSize))
+    err = r.ParseMultipartForm(int64(t.maxFileSize()))
     if err != nil {
-        return nil, errors.New("the uploaded file is too big")
+        if errors.Is(err, multipart.ErrMessageTooLarge) {
+            return nil, fmt.Errorf("upload exceeds %s limit", Size(t.maxFileSize()))
+        }
+        return nil, fmt.Errorf("invalid multipart upload: %w", err)
     }
 
     for _, headers := range r.MultipartForm.File {
         for _, header := range headers {
             uploadedFiles, err = func(uploadedFiles []*UploadedFile) ([]*UploadedFile, error) {
+                if limit := int64(t.maxFileSize()); header.Size > limit {
+                    return nil, &FileTooLargeError{FileName: header.Filename, Size: header.Size, Limit: limit}
+                }
+
                 var (
                     uploadedFile UploadedFile
                     infile       multipart.File
@@ -167,31 +228,9 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 }
 
 // CreateDirIfNotExist creates a directory and all necessary parents, if it does not exist
+// It fails with ErrNotDirectory if path exists but is not a directory
 func (t *Tools) CreateDirIfNotExist(path string) error {
-    const mode = 0755
-    var err error
-    if _, err = os.Stat(path); os.IsNotExist(err) {
-        err = os.MkdirAll(path, mode)
-        if err != nil {
-            return err
-        }
-    }
-    return nil
-}
-
-// Slugify creates slug from a string
-func (t *Tools) Slugify(s string) (string, error) {
-    if s == "" {
-        return "", errors.New("empty string is not permitted")
-    }
-
-    re := regexp.MustCompile(`[^a-z\d]+`)
-    slug := strings.Trim(re.ReplaceAllString(strings.ToLower(s), "-"), "-")
-    if len(slug) == 0 {
-        return "", errors.New("after removing characters, slug is zero length")
-    }
-
-    return slug, nil
+    return t.EnsureDir(path)
 }
 
 // DownloadStaticFile downloads a file, and tries to do not display it in the browser window via content disposition
@@ -211,10 +250,7 @@ type JSONResponse struct {
 
 // ReadJSON tries to read the body of a request and converts it from json to a go data variable
 func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
-    maxBytes := 1024 * 1024 // 1 MB
-    if t.MaxJSONSize != 0 {
-        maxBytes = t.MaxJSONSize
-    }
+    maxBytes := t.maxJSONSize()
     r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
     dec := json.NewDecoder(r.Body)
     if !t.AllowUnknownFields {
@@ -222,59 +258,72 @@ func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{
     }
     err := dec.Decode(data)
     if err != nil {
-        var (
-            syntaxError           *json.SyntaxError
-            unmarshalTypeError    *json.UnmarshalTypeError
-            invalidUnmarshalError *json.InvalidUnmarshalError
-        )
-        switch {
-        case errors.As(err, &syntaxError):
-            return fmt.Errorf("body contains badly-formed JSON (at character %d)",
-                syntaxError.Offset)
-        case errors.Is(err, io.ErrUnexpectedEOF):
-            return errors.New("body contains badly-formed JSON")
-        case errors.As(err, &unmarshalTypeError):
-            if unmarshalTypeError.Field != "" {
-                return fmt.Errorf("body conatians incorrect JSON type for field %q",
-                    unmarshalTypeError.Field)
-            }
-            return fmt.Errorf("body contains incorrect JSON type (at) character %d",
-                unmarshalTypeError.Offset)
-        case errors.Is(err, io.EOF):
-            return errors.New("body must not be empty")
-        case strings.HasPrefix(err.Error(), "json: unknown field"):
-            fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-            return fmt.Errorf("body contains unknown key %s", fieldName)
-        case err.Error() == "http: request body too large":
-            return fmt.Errorf("body must not be large than %d bytes", maxBytes)
-        case errors.As(err, &invalidUnmarshalError):
-            return fmt.Errorf("error unmarshalling JSON: %s", err.Error())
-
-        default:
-            return err
-        }
+        return jsonDecodeError(err, maxBytes)
     }
     err = dec.Decode(&struct{}{})
     if err != io.EOF {
         return errors.New("body must contain only one JSON value")
     }
+    if t.ValidateJSON {
+        return t.Validate(data)
+    }
     return nil
 }
 
+// jsonDecodeError translates an error returned by json.Decoder into a message suitable for clients
+func jsonDecodeError(err error, maxBytes int) error {
+    var (
+        syntaxError           *json.SyntaxError
+        unmarshalTypeError    *json.UnmarshalTypeError
+        invalidUnmarshalError *json.InvalidUnmarshalError
+    )
+    switch {
+    case errors.As(err, &syntaxError):
+        return fmt.Errorf("body contains badly-formed JSON (at character %d)",
+            syntaxError.Offset)
+    case errors.Is(err, io.ErrUnexpectedEOF):
+        return errors.New("body contains badly-formed JSON")
+    case errors.As(err, &unmarshalTypeError):
+        if unmarshalTypeError.Field != "" {
+            return fmt.Errorf("body conatians incorrect JSON type for field %q",
+                unmarshalTypeError.Field)
+        }
+        return fmt.Errorf("body contains incorrect JSON type (at) character %d",
+            unmarshalTypeError.Offset)
+    case errors.Is(err, io.EOF):
+        return errors.New("body must not be empty")
+    case strings.HasPrefix(err.Error(), "json: unknown field"):
+        fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
+        return fmt.Errorf("body contains unknown key %s", fieldName)
+    case err.Error() == "http: request body too large":
+        return fmt.Errorf("body must not be large than %d bytes", maxBytes)
+    case errors.As(err, &invalidUnmarshalError):
+        return fmt.Errorf("error unmarshalling JSON: %s", err.Error())
+
+    default:
+        return err
+    }
+}
+
 // WriteJSON takes response status code and arbitrary data and writes json to the client
+// The Content-Type is application/json unless headers specify another one
 func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
     out, err := json.Marshal(data)
     if err != nil {
         return err
     }
 
+    contentType := "application/json"
     if len(headers) > 0 {
         for k, v := range headers[0] {
             w.Header()[k] = v
         }
+        if ct := headers[0].Get("Content-Type"); ct != "" {
+            contentType = ct
+        }
     }
 
-    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Content-Type", contentType)
     w.WriteHeader(status)
     _, err = w.Write(out)
     if err != nil {
@@ -284,16 +333,41 @@ func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, h
 }
 
 // ErrorJSON takes an error and optionally a status code, then generates and sends JSON error message
+// When no status is given, the status of a *Problem is used, then the Errors registry is consulted,
+// ValidationErrors are sent with 422 and anything else with 400.
+// The body format is selected by ErrorFormat, or ErrorRenderer if set
 func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
-    statusCode := http.StatusBadRequest
+    statusCode, public, hidden := t.errorResponse(err, status)
+    if t.RedactServerErrors && statusCode >= http.StatusInternalServerError {
+        public = t.redactError(w, err, public, statusCode)
+    } else if hidden {
+        t.logger().Error("error hidden from client", "status", statusCode, "error", err.Error())
+    }
+    err = public
+
+    if t.ErrorRenderer != nil {
+        return t.ErrorRenderer(w, err, statusCode)
+    }
 
-    if len(status) > 0 {
-        statusCode = status[0]
+    details := problemFor(err, statusCode)
+    if t.ErrorFormat == ErrorFormatProblem {
+        headers := make(http.Header)
+        headers.Set("Content-Type", "application/problem+json")
+        return t.WriteJSON(w, statusCode, details, headers)
     }
 
     var payload JSONResponse
     payload.Error = true
-    payload.Message = err.Error()
+    payload.Message = details.Detail
+    if payload.Message == "" {
+        payload.Message = details.Title
+    }
+    var validationErrors ValidationErrors
+    if errors.As(err, &validationErrors) {
+        payload.Data = validationErrors
+    } else if len(details.Extensions) > 0 {
+        payload.Data = details.Extensions
+    }
 
     return t.WriteJSON(w, statusCode, payload)
 }
@@ -301,12 +375,20 @@ func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error
 // PushJSONToRemote posts data to URL as JSON
 // returns response, status code, error
 // client is optional - if none is specified, we use standard http.Client
+// Failed calls are retried according to Retry, and CircuitBreaker fails fast for unhealthy hosts
+// Requests are authenticated by Authenticator, if set, on every attempt
+// Large bodies are gzipped according to Compression. Unlike JSONClient, PushJSONToRemote does not
+// ask for gzipped responses, so they are left to the transparent decompression of http.Transport
 func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
     // create json
     jsonData, err := json.Marshal(data)
     if err != nil {
         return nil, 0, err
     }
+    jsonData, compressed, err := t.Compression.compress(jsonData)
+    if err != nil {
+        return nil, 0, err
+    }
 
     // check for custom http client
     httpClient := &http.Client{}
@@ -314,15 +396,24 @@ func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.C
         httpClient = client[0]
     }
 
-    // build the request and set the header
-    request, err := http.NewRequest("POST", uri, bytes.NewBuffer(jsonData))
-    if err != nil {
-        return nil, 0, err
+    // build the request and set the header, once per attempt
+    newRequest := func() (*http.Request, error) {
+        request, err := http.NewRequest("POST", uri, bytes.NewBuffer(jsonData))
+        if err != nil {
+            return nil, err
+        }
+        request.Header.Set("Content-Type", "application/json")
+        t.Compression.setHeaders(request, compressed, false)
+        if t.Authenticator != nil {
+            if err = t.Authenticator.Authenticate(request, jsonData); err != nil {
+                return nil, err
+            }
+        }
+        return request, nil
     }
-    request.Header.Set("Content-Type", "application/json")
 
     // call remote uri
-    response, err := httpClient.Do(request)
+    response, err := t.doWithRetry(context.Background(), httpClient, newRequest)
     if err != nil {
         return nil, 0, err
     }
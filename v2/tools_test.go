@@ -178,6 +178,43 @@ func TestTools_UploadFile(t *testing.T) {
 
 }
 
+func TestTools_UploadFiles_TooLarge(t *testing.T) {
+    body := &bytes.Buffer{}
+    writer := multipart.NewWriter(body)
+    part, err := writer.CreateFormFile("file", "large.txt")
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, _ = part.Write(bytes.Repeat([]byte("a"), 1500))
+    _ = writer.Close()
+
+    request := httptest.NewRequest("POST", "/", body)
+    request.Header.Add("Content-Type", writer.FormDataContentType())
+
+    testTools := Tools{MaxFileSize: 1024}
+    uploadDir := t.TempDir()
+    _, err = testTools.UploadFiles(request, uploadDir)
+
+    var tooLarge *FileTooLargeError
+    if !errors.As(err, &tooLarge) {
+        t.Fatalf("expected a *FileTooLargeError, got %v", err)
+    }
+    if tooLarge.FileName != "large.txt" || tooLarge.Size != 1500 || tooLarge.Limit != 1024 {
+        t.Errorf("unexpected error %+v", tooLarge)
+    }
+    if err.Error() != "file exceeds 1 KiB limit" {
+        t.Errorf("unexpected message %q", err.Error())
+    }
+    if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+        t.Errorf("expected no files to be written, got %d", len(entries))
+    }
+
+    tooLarge.Limit = 10_000_000
+    if err.Error() != "file exceeds 10 MB limit" {
+        t.Errorf("unexpected message %q", err.Error())
+    }
+}
+
 func TestTools_CreateDirIfNotExist(t *testing.T) {
     var testTool Tools
     err := testTool.CreateDirIfNotExist("./testdata/myDir")
@@ -377,7 +414,7 @@ func TestTools_WriteJSON(t *testing.T) {
 
     err := testTool.WriteJSON(rr, http.StatusOK, payload, headers)
     if err != nil {
-        fmt.Errorf("failed to write JSON: %v", err)
+        t.Errorf("failed to write JSON: %v", err)
     }
 }
 
@@ -0,0 +1,338 @@
+package toolkit
+
+import (
+    "errors"
+    "fmt"
+    "net/mail"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "unicode/utf8"
+)
+
+// ValidationErrors maps a field path (for example "address.city" or "items[0].name")
+// to the list of messages describing why the field failed validation
+type ValidationErrors map[string][]string
+
+// Error returns all validation messages as a single string, sorted by field path
+func (v ValidationErrors) Error() string {
+    fields := make([]string, 0, len(v))
+    for field := range v {
+        fields = append(fields, field)
+    }
+    sort.Strings(fields)
+
+    parts := make([]string, 0, len(fields))
+    for _, field := range fields {
+        parts = append(parts, fmt.Sprintf("%s %s", field, strings.Join(v[field], ", ")))
+    }
+    return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func (v ValidationErrors) add(field, message string) {
+    v[field] = append(v[field], message)
+}
+
+// validationRule is a single parsed entry of a validate struct tag, e.g. min=3
+type validationRule struct {
+    name  string
+    param string
+}
+
+// validatedField holds parsed validation information for a single struct field
+type validatedField struct {
+    index []int
+    name  string
+    rules []validationRule
+}
+
+// validationCache stores parsed struct fields keyed by reflect.Type
+var validationCache sync.Map
+
+// Validate checks data against the rules declared in its `validate` struct tags.
+// Supported rules are required, omitempty, min, max, len, email and oneof.
+// Nested structs, pointers, slices, arrays and maps are validated recursively.
+// It returns ValidationErrors when one or more fields are invalid, or another error
+// when a tag is malformed
+func (t *Tools) Validate(data interface{}) error {
+    errs := make(ValidationErrors)
+    if err := validateValue(reflect.ValueOf(data), "", errs); err != nil {
+        return err
+    }
+    if len(errs) > 0 {
+        return errs
+    }
+    return nil
+}
+
+// validateValue walks v and records failures in errs under the path prefix
+func validateValue(v reflect.Value, path string, errs ValidationErrors) error {
+    for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+        if v.IsNil() {
+            return nil
+        }
+        v = v.Elem()
+    }
+
+    switch v.Kind() {
+    case reflect.Struct:
+        fields, err := structFields(v.Type())
+        if err != nil {
+            return err
+        }
+        for _, f := range fields {
+            fv, err := v.FieldByIndexErr(f.index)
+            if err != nil {
+                // the field is inside a nil embedded pointer
+                continue
+            }
+            fieldPath := joinPath(path, f.name)
+            if err = applyRules(fv, fieldPath, f.rules, errs); err != nil {
+                return err
+            }
+            if err = validateValue(fv, fieldPath, errs); err != nil {
+                return err
+            }
+        }
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < v.Len(); i++ {
+            if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+                return err
+            }
+        }
+    case reflect.Map:
+        iter := v.MapRange()
+        for iter.Next() {
+            if err := validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func joinPath(prefix, name string) string {
+    if prefix == "" {
+        return name
+    }
+    return prefix + "." + name
+}
+
+// structFields returns the exported fields of typ together with their parsed rules. As in
+// encoding/json, the fields of untagged embedded structs and struct pointers are inlined
+func structFields(typ reflect.Type) ([]validatedField, error) {
+    if cached, ok := validationCache.Load(typ); ok {
+        return cached.([]validatedField), nil
+    }
+
+    fields, err := collectValidatedFields(typ, nil, map[reflect.Type]bool{})
+    if err != nil {
+        return nil, err
+    }
+    validationCache.Store(typ, fields)
+    return fields, nil
+}
+
+// collectValidatedFields returns the fields of typ, which is reached through index. Embedded
+// structs already being collected, which embed themselves through a pointer, are not inlined again
+func collectValidatedFields(typ reflect.Type, index []int, inlining map[reflect.Type]bool) ([]validatedField, error) {
+    inlining[typ] = true
+    defer delete(inlining, typ)
+
+    var fields, embedded []validatedField
+    for i := 0; i < typ.NumField(); i++ {
+        sf := typ.Field(i)
+        fieldIndex := append(append([]int(nil), index...), i)
+        if embeddedType, ok := inlinedStruct(sf); ok {
+            if inlining[embeddedType] {
+                continue
+            }
+            inner, err := collectValidatedFields(embeddedType, fieldIndex, inlining)
+            if err != nil {
+                return nil, err
+            }
+            embedded = append(embedded, inner...)
+            continue
+        }
+        if !sf.IsExported() {
+            continue
+        }
+
+        name, ok := fieldName(sf, "json")
+        if !ok {
+            continue
+        }
+
+        rules, err := parseRules(sf.Tag.Get("validate"))
+        if err != nil {
+            return nil, fmt.Errorf("invalid validate tag on %s.%s: %w", typ.Name(), sf.Name, err)
+        }
+        fields = append(fields, validatedField{index: fieldIndex, name: name, rules: rules})
+    }
+
+    // fields of the outer struct hide embedded fields with the same name
+    for _, f := range embedded {
+        hidden := false
+        for _, outer := range fields {
+            if outer.name == f.name {
+                hidden = true
+                break
+            }
+        }
+        if !hidden {
+            fields = append(fields, f)
+        }
+    }
+    return fields, nil
+}
+
+// inlinedStruct returns the struct type of an untagged embedded struct or struct pointer, whose
+// fields encoding/json inlines. Pointers to unexported structs are not, as they cannot be allocated
+func inlinedStruct(sf reflect.StructField) (reflect.Type, bool) {
+    if !sf.Anonymous || sf.Tag.Get("json") != "" {
+        return nil, false
+    }
+    typ := sf.Type
+    if typ.Kind() == reflect.Pointer {
+        if !sf.IsExported() {
+            return nil, false
+        }
+        typ = typ.Elem()
+    }
+    return typ, typ.Kind() == reflect.Struct
+}
+
+// parseRules parses a validate tag such as "required,min=3,oneof=a b"
+func parseRules(tag string) ([]validationRule, error) {
+    if tag == "" {
+        return nil, nil
+    }
+
+    var rules []validationRule
+    for _, part := range strings.Split(tag, ",") {
+        name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+        switch name {
+        case "required", "omitempty", "email":
+        case "min", "max", "len":
+            if _, err := strconv.ParseFloat(param, 64); err != nil {
+                return nil, fmt.Errorf("rule %q needs a numeric parameter", name)
+            }
+        case "oneof":
+            if strings.TrimSpace(param) == "" {
+                return nil, errors.New(`rule "oneof" needs at least one value`)
+            }
+        default:
+            return nil, fmt.Errorf("unknown rule %q", name)
+        }
+        rules = append(rules, validationRule{name: name, param: param})
+    }
+    return rules, nil
+}
+
+// applyRules checks a single field value against its rules
+func applyRules(v reflect.Value, path string, rules []validationRule, errs ValidationErrors) error {
+    if len(rules) == 0 {
+        return nil
+    }
+
+    for _, rule := range rules {
+        if rule.name == "omitempty" && v.IsZero() {
+            return nil
+        }
+    }
+
+    for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+        if v.IsNil() {
+            for _, rule := range rules {
+                if rule.name == "required" {
+                    errs.add(path, "is required")
+                }
+            }
+            return nil
+        }
+        v = v.Elem()
+    }
+
+    for _, rule := range rules {
+        switch rule.name {
+        case "required":
+            if v.IsZero() || (isCollection(v) && v.Len() == 0) {
+                errs.add(path, "is required")
+                // no point in reporting more errors for a missing value
+                return nil
+            }
+        case "min", "max", "len":
+            limit, _ := strconv.ParseFloat(rule.param, 64)
+            size, unit, ok := measure(v)
+            if !ok {
+                return fmt.Errorf("rule %q cannot be applied to %s (%s)", rule.name, path, v.Kind())
+            }
+            switch {
+            case rule.name == "min" && size < limit:
+                errs.add(path, fmt.Sprintf("must be at least %s%s", rule.param, unit))
+            case rule.name == "max" && size > limit:
+                errs.add(path, fmt.Sprintf("must be at most %s%s", rule.param, unit))
+            case rule.name == "len" && size != limit:
+                errs.add(path, fmt.Sprintf("must be exactly %s%s", rule.param, unit))
+            }
+        case "email":
+            if v.Kind() != reflect.String {
+                return fmt.Errorf("rule \"email\" cannot be applied to %s (%s)", path, v.Kind())
+            }
+            if !isEmail(v.String()) {
+                errs.add(path, "must be a valid email address")
+            }
+        case "oneof":
+            options := strings.Fields(rule.param)
+            value := fmt.Sprint(v.Interface())
+            found := false
+            for _, option := range options {
+                if value == option {
+                    found = true
+                    break
+                }
+            }
+            if !found {
+                errs.add(path, fmt.Sprintf("must be one of: %s", strings.Join(options, ", ")))
+            }
+        }
+    }
+    return nil
+}
+
+func isCollection(v reflect.Value) bool {
+    switch v.Kind() {
+    case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+        return true
+    }
+    return false
+}
+
+// measure returns the value used by min, max and len: the number of characters of a string,
+// the length of a collection or the numeric value itself, along with a unit for messages
+func measure(v reflect.Value) (float64, string, bool) {
+    switch v.Kind() {
+    case reflect.String:
+        return float64(utf8.RuneCountInString(v.String())), " characters long", true
+    case reflect.Slice, reflect.Array, reflect.Map:
+        return float64(v.Len()), " items", true
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(v.Int()), "", true
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return float64(v.Uint()), "", true
+    case reflect.Float32, reflect.Float64:
+        return v.Float(), "", true
+    }
+    return 0, "", false
+}
+
+// isEmail reports whether s is a bare email address, such as "joe@example.com"
+func isEmail(s string) bool {
+    addr, err := mail.ParseAddress(s)
+    if err != nil {
+        return false
+    }
+    return addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+}
@@ -0,0 +1,226 @@
+package toolkit
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "sort"
+    "strings"
+    "testing"
+)
+
+type validateAddress struct {
+    City string `json:"city" validate:"required"`
+    Zip  string `json:"zip" validate:"omitempty,len=5"`
+}
+
+type validateItem struct {
+    Name string `json:"name" validate:"required,max=10"`
+}
+
+type validateUser struct {
+    Name     string                  `json:"name" validate:"required,min=3,max=50"`
+    Email    string                  `json:"email" validate:"required,email"`
+    Role     string                  `json:"role" validate:"oneof=admin user"`
+    Age      int                     `json:"age" validate:"min=18"`
+    Address  validateAddress         `json:"address"`
+    Manager  *validateAddress        `json:"manager"`
+    Items    []validateItem          `json:"items" validate:"max=2"`
+    Contacts map[string]validateItem `json:"contacts"`
+}
+
+var validateTests = []struct {
+    name           string
+    user           validateUser
+    expectedFields []string
+}{
+    {
+        name: "valid",
+        user: validateUser{
+            Name: "Joe", Email: "joe@example.com", Role: "admin", Age: 30,
+            Address: validateAddress{City: "Oslo"},
+        },
+    },
+    {
+        name:           "required and min",
+        user:           validateUser{Name: "Jo", Role: "user", Age: 18, Address: validateAddress{City: "Oslo"}},
+        expectedFields: []string{"name", "email"},
+    },
+    {
+        name: "email and oneof",
+        user: validateUser{
+            Name: "Joe", Email: "Joe <joe@example.com>", Role: "root", Age: 18,
+            Address: validateAddress{City: "Oslo"},
+        },
+        expectedFields: []string{"email", "role"},
+    },
+    {
+        name: "nested struct and pointer",
+        user: validateUser{
+            Name: "Joe", Email: "joe@example.com", Role: "user", Age: 18,
+            Address: validateAddress{Zip: "123"},
+            Manager: &validateAddress{},
+        },
+        expectedFields: []string{"address.city", "address.zip", "manager.city"},
+    },
+    {
+        name: "slices and maps",
+        user: validateUser{
+            Name: "Joe", Email: "joe@example.com", Role: "user", Age: 18,
+            Address:  validateAddress{City: "Oslo"},
+            Items:    []validateItem{{Name: "ok"}, {Name: "far too long"}, {Name: "x"}},
+            Contacts: map[string]validateItem{"home": {}},
+        },
+        expectedFields: []string{"items", "items[1].name", "contacts[home].name"},
+    },
+}
+
+func TestTools_Validate(t *testing.T) {
+    var testTools Tools
+
+    for _, test := range validateTests {
+        err := testTools.Validate(&test.user)
+        if len(test.expectedFields) == 0 {
+            if err != nil {
+                t.Errorf("%s: error not expected, but one received: %s", test.name, err.Error())
+            }
+            continue
+        }
+
+        var validationErrors ValidationErrors
+        if !errors.As(err, &validationErrors) {
+            t.Errorf("%s: expected ValidationErrors, got %v", test.name, err)
+            continue
+        }
+        if len(validationErrors) != len(test.expectedFields) {
+            t.Errorf("%s: expected %d invalid fields, got %d: %v",
+                test.name, len(test.expectedFields), len(validationErrors), validationErrors)
+        }
+        for _, field := range test.expectedFields {
+            if _, ok := validationErrors[field]; !ok {
+                t.Errorf("%s: expected error for field %q", test.name, field)
+            }
+        }
+    }
+}
+
+type validateBase struct {
+    ID   string `json:"id" validate:"required"`
+    Note string `json:"note" validate:"max=3"`
+}
+
+type validateTimestamps struct {
+    Created string `json:"created" validate:"required"`
+}
+
+// ValidateAudit is exported, so that a pointer to it can be embedded
+type ValidateAudit struct {
+    By string `json:"by" validate:"required"`
+}
+
+// ValidateNode embeds itself
+type ValidateNode struct {
+    *ValidateNode
+    Name string `json:"name" validate:"required"`
+}
+
+func TestTools_ValidateEmbedded(t *testing.T) {
+    var testTools Tools
+    data := struct {
+        validateBase
+        *validateTimestamps
+        Note  string       `json:"note"`
+        Named validateBase `json:"named"`
+    }{
+        validateBase: validateBase{Note: "too long"},
+        Note:         "outer fields hide embedded ones",
+    }
+
+    err := testTools.Validate(&data)
+    var validationErrors ValidationErrors
+    if !errors.As(err, &validationErrors) {
+        t.Fatalf("expected ValidationErrors, got %v", err)
+    }
+    got := make([]string, 0, len(validationErrors))
+    for field := range validationErrors {
+        got = append(got, field)
+    }
+    sort.Strings(got)
+    // embedded fields are inlined as in encoding/json, the embedded Note is hidden
+    // and the unexported embedded pointer is skipped
+    if strings.Join(got, ",") != "id,named.id" {
+        t.Errorf("expected errors for id and named.id, got %v", got)
+    }
+
+    withPointer := struct {
+        *ValidateAudit
+        Name string `json:"name"`
+    }{}
+    // a nil embedded pointer has nothing to validate
+    if err = testTools.Validate(&withPointer); err != nil {
+        t.Errorf("error not expected, but one received: %s", err.Error())
+    }
+    withPointer.ValidateAudit = &ValidateAudit{}
+    err = testTools.Validate(&withPointer)
+    if !errors.As(err, &validationErrors) || len(validationErrors) != 1 || validationErrors["by"] == nil {
+        t.Errorf("expected an error for by, got %v", err)
+    }
+
+    // as in encoding/json, a struct embedding itself is inlined once, so that the inner
+    // name is hidden by the outer one
+    node := ValidateNode{ValidateNode: &ValidateNode{}, Name: "root"}
+    if err = testTools.Validate(&node); err != nil {
+        t.Errorf("error not expected, but one received: %s", err.Error())
+    }
+}
+
+func TestTools_ValidateBadTag(t *testing.T) {
+    var testTools Tools
+    data := struct {
+        Name string `validate:"min=abc"`
+    }{}
+
+    err := testTools.Validate(&data)
+    var validationErrors ValidationErrors
+    if err == nil || errors.As(err, &validationErrors) {
+        t.Errorf("expected tag error, got %v", err)
+    }
+}
+
+func TestTools_ReadJSONValidate(t *testing.T) {
+    testTools := Tools{ValidateJSON: true}
+
+    var data struct {
+        Name string `json:"name" validate:"required,min=3"`
+    }
+
+    req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name": "ab"}`)))
+    rr := httptest.NewRecorder()
+    err := testTools.ReadJSON(rr, req, &data)
+
+    var validationErrors ValidationErrors
+    if !errors.As(err, &validationErrors) {
+        t.Fatalf("expected ValidationErrors, got %v", err)
+    }
+
+    err = testTools.ErrorJSON(rr, err)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rr.Code != http.StatusUnprocessableEntity {
+        t.Errorf("expected status code 422, got: %d", rr.Code)
+    }
+
+    var payload struct {
+        Error bool                `json:"error"`
+        Data  map[string][]string `json:"data"`
+    }
+    if err = json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+        t.Fatal("received error when decoding JSON:", err)
+    }
+    if len(payload.Data["name"]) != 1 {
+        t.Errorf("expected one message for name, got %v", payload.Data)
+    }
+}
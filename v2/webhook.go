@@ -0,0 +1,207 @@
+package toolkit
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ErrInvalidSignature is returned when a webhook signature is missing, malformed, wrong or expired
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// SignatureScheme selects how a WebhookVerifier reads and checks signatures
+type SignatureScheme int
+
+const (
+    // SignatureSchemeHMAC checks signatures made by HMACSigner: "sha256=<hex>" of
+    // "<timestamp>.<body>" in X-Signature, with the timestamp in X-Timestamp
+    SignatureSchemeHMAC SignatureScheme = iota
+    // SignatureSchemeStripe checks Stripe style "t=<timestamp>,v1=<hex>" signatures of
+    // "<timestamp>.<body>" in Stripe-Signature; several v1 entries may be present
+    SignatureSchemeStripe
+    // SignatureSchemeGitHub checks GitHub style "sha256=<hex>" signatures of the body in
+    // X-Hub-Signature-256. They carry no timestamp, so replays cannot be detected
+    SignatureSchemeGitHub
+)
+
+// WebhookVerifier verifies HMAC-SHA256 signatures of incoming webhooks
+type WebhookVerifier struct {
+    // Secrets holds the accepted secrets; more than one can be given while rotating them
+    Secrets [][]byte
+    Scheme  SignatureScheme
+    // SignatureHeader overrides the header holding the signature for the scheme
+    SignatureHeader string
+    // TimestampHeader overrides the header holding the timestamp for SignatureSchemeHMAC
+    TimestampHeader string
+    // Tolerance is the maximum age, and clock skew, of a signed timestamp; 5 minutes if zero
+    Tolerance time.Duration
+
+    now func() time.Time
+}
+
+func (v *WebhookVerifier) signatureHeader() string {
+    if v.SignatureHeader != "" {
+        return v.SignatureHeader
+    }
+    switch v.Scheme {
+    case SignatureSchemeStripe:
+        return "Stripe-Signature"
+    case SignatureSchemeGitHub:
+        return "X-Hub-Signature-256"
+    }
+    return DefaultSignatureHeader
+}
+
+// Verify checks the signature of body sent with header. Errors wrap ErrInvalidSignature
+func (v *WebhookVerifier) Verify(header http.Header, body []byte) error {
+    if len(v.Secrets) == 0 {
+        return errors.New("webhook verifier has no secrets")
+    }
+
+    name := v.signatureHeader()
+    value := header.Get(name)
+    if value == "" {
+        return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, name)
+    }
+
+    var (
+        timestamp  string
+        signatures []string
+    )
+    switch v.Scheme {
+    case SignatureSchemeStripe:
+        for _, part := range strings.Split(value, ",") {
+            key, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+            switch key {
+            case "t":
+                timestamp = val
+            case "v1":
+                signatures = append(signatures, val)
+            }
+        }
+        if timestamp == "" {
+            return fmt.Errorf("%w: missing timestamp", ErrInvalidSignature)
+        }
+    case SignatureSchemeGitHub, SignatureSchemeHMAC:
+        signature, ok := strings.CutPrefix(value, "sha256=")
+        if !ok {
+            return fmt.Errorf("%w: unsupported signature format", ErrInvalidSignature)
+        }
+        signatures = append(signatures, signature)
+        if v.Scheme == SignatureSchemeHMAC {
+            timestampHeader := v.TimestampHeader
+            if timestampHeader == "" {
+                timestampHeader = DefaultTimestampHeader
+            }
+            if timestamp = header.Get(timestampHeader); timestamp == "" {
+                return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, timestampHeader)
+            }
+        }
+    default:
+        return fmt.Errorf("unknown signature scheme %d", v.Scheme)
+    }
+
+    if timestamp != "" {
+        if err := v.checkTimestamp(timestamp); err != nil {
+            return err
+        }
+    }
+
+    for _, secret := range v.Secrets {
+        expected, _ := hex.DecodeString(signHMAC(secret, timestamp, body))
+        for _, signature := range signatures {
+            received, err := hex.DecodeString(signature)
+            if err == nil && hmac.Equal(expected, received) {
+                return nil
+            }
+        }
+    }
+    return fmt.Errorf("%w: signature does not match", ErrInvalidSignature)
+}
+
+// checkTimestamp rejects timestamps outside the tolerance window, to prevent replays
+func (v *WebhookVerifier) checkTimestamp(timestamp string) error {
+    seconds, err := strconv.ParseInt(timestamp, 10, 64)
+    if err != nil {
+        return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+    }
+
+    tolerance := v.Tolerance
+    if tolerance <= 0 {
+        tolerance = 5 * time.Minute
+    }
+    now := time.Now()
+    if v.now != nil {
+        now = v.now()
+    }
+
+    age := now.Sub(time.Unix(seconds, 0))
+    if age > tolerance || age < -tolerance {
+        return fmt.Errorf("%w: timestamp outside the tolerance window", ErrInvalidSignature)
+    }
+    return nil
+}
+
+// readSignedBody reads the request body, limited to MaxJSONSize, verifies its signature and
+// replaces r.Body so that it can be read again. A gzip encoded body is verified as received,
+// then decompressed within MaxJSONSize
+func (t *Tools) readSignedBody(w http.ResponseWriter, r *http.Request, verifier *WebhookVerifier) error {
+    maxBytes := t.maxJSONSize()
+
+    body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+    if err != nil {
+        var maxBytesError *http.MaxBytesError
+        if errors.As(err, &maxBytesError) {
+            return fmt.Errorf("body must not be large than %d bytes", maxBytes)
+        }
+        return err
+    }
+
+    if err = verifier.Verify(r.Header, body); err != nil {
+        return err
+    }
+    if gzipEncoded(r.Header) {
+        if body, err = gunzip(body, maxBytes); err != nil {
+            return err
+        }
+        r.Header.Del("Content-Encoding")
+    }
+    r.Body = io.NopCloser(bytes.NewReader(body))
+    r.ContentLength = int64(len(body))
+    return nil
+}
+
+// ReadSignedJSON verifies the signature of a webhook request with verifier before decoding
+// its body into data with the same rules and errors as ReadJSON. Signature failures wrap
+// ErrInvalidSignature
+func (t *Tools) ReadSignedJSON(w http.ResponseWriter, r *http.Request, verifier *WebhookVerifier, data interface{}) error {
+    if err := t.readSignedBody(w, r, verifier); err != nil {
+        return err
+    }
+    return t.ReadJSON(w, r, data)
+}
+
+// VerifySignature returns a middleware which rejects requests whose signature is not valid
+// for verifier with a 401 error sent by ErrorJSON. The body remains readable by next
+func (t *Tools) VerifySignature(verifier *WebhookVerifier) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if err := t.readSignedBody(w, r, verifier); err != nil {
+                status := http.StatusBadRequest
+                if errors.Is(err, ErrInvalidSignature) {
+                    status = http.StatusUnauthorized
+                }
+                _ = t.ErrorJSON(w, err, status)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
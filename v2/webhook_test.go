@@ -0,0 +1,123 @@
+package toolkit
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+const webhookBody = `{"foo": "bar"}`
+
+var webhookNow = time.Unix(1700000000, 0)
+
+// signedRequest returns a webhook request carrying the given headers
+func signedRequest(body string, headers map[string]string) *http.Request {
+    req, _ := http.NewRequest("POST", "/hook", strings.NewReader(body))
+    for k, v := range headers {
+        req.Header.Set(k, v)
+    }
+    return req
+}
+
+func TestTools_ReadSignedJSON(t *testing.T) {
+    secret := []byte("whsec")
+    ts := fmt.Sprint(webhookNow.Unix())
+    stale := fmt.Sprint(webhookNow.Add(-10 * time.Minute).Unix())
+    sig := signHMAC(secret, ts, []byte(webhookBody))
+
+    tests := []struct {
+        name             string
+        scheme           SignatureScheme
+        body             string
+        headers          map[string]string
+        invalidSignature bool
+        errorExpected    bool
+    }{
+        {name: "hmac", scheme: SignatureSchemeHMAC, body: webhookBody, headers: map[string]string{"X-Signature": "sha256=" + sig, "X-Timestamp": ts}},
+        {name: "hmac stale", scheme: SignatureSchemeHMAC, body: webhookBody, headers: map[string]string{"X-Signature": "sha256=" + signHMAC(secret, stale, []byte(webhookBody)), "X-Timestamp": stale}, invalidSignature: true},
+        {name: "hmac missing timestamp", scheme: SignatureSchemeHMAC, body: webhookBody, headers: map[string]string{"X-Signature": "sha256=" + sig}, invalidSignature: true},
+        {name: "stripe", scheme: SignatureSchemeStripe, body: webhookBody, headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v1=deadbeef,v1=" + sig}},
+        {name: "stripe tampered", scheme: SignatureSchemeStripe, body: `{"foo": "baz"}`, headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + sig}, invalidSignature: true},
+        {name: "github", scheme: SignatureSchemeGitHub, body: webhookBody, headers: map[string]string{"X-Hub-Signature-256": "sha256=" + signHMAC(secret, "", []byte(webhookBody))}},
+        {name: "missing header", scheme: SignatureSchemeGitHub, body: webhookBody, invalidSignature: true},
+        {name: "valid signature, bad json", scheme: SignatureSchemeGitHub, body: `{"foo": 1}`, headers: map[string]string{"X-Hub-Signature-256": "sha256=" + signHMAC(secret, "", []byte(`{"foo": 1}`))}, errorExpected: true},
+    }
+
+    var testTools Tools
+    for _, test := range tests {
+        verifier := &WebhookVerifier{
+            Secrets: [][]byte{[]byte("old"), secret},
+            Scheme:  test.scheme,
+            now:     func() time.Time { return webhookNow },
+        }
+
+        var data struct {
+            Foo string `json:"foo"`
+        }
+        err := testTools.ReadSignedJSON(httptest.NewRecorder(), signedRequest(test.body, test.headers), verifier, &data)
+
+        switch {
+        case test.invalidSignature && !errors.Is(err, ErrInvalidSignature):
+            t.Errorf("%s: expected ErrInvalidSignature, got %v", test.name, err)
+        case test.errorExpected && (err == nil || errors.Is(err, ErrInvalidSignature)):
+            t.Errorf("%s: expected decoding error, got %v", test.name, err)
+        case !test.invalidSignature && !test.errorExpected && (err != nil || data.Foo != "bar"):
+            t.Errorf("%s: expected decoded body, got %+v %v", test.name, data, err)
+        }
+    }
+}
+
+func TestTools_VerifySignature(t *testing.T) {
+    var testTools Tools
+    verifier := &WebhookVerifier{Secrets: [][]byte{[]byte("whsec")}, Scheme: SignatureSchemeGitHub}
+
+    var received string
+    handler := testTools.VerifySignature(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var data struct {
+            Foo string `json:"foo"`
+        }
+        if err := testTools.ReadJSON(w, r, &data); err != nil {
+            t.Error(err)
+        }
+        received = data.Foo
+    }))
+
+    rr := httptest.NewRecorder()
+    handler.ServeHTTP(rr, signedRequest(webhookBody, map[string]string{
+        "X-Hub-Signature-256": "sha256=" + signHMAC([]byte("whsec"), "", []byte(webhookBody)),
+    }))
+    if rr.Code != http.StatusOK || received != "bar" {
+        t.Errorf("expected request to pass, got %d %q", rr.Code, received)
+    }
+
+    rr = httptest.NewRecorder()
+    handler.ServeHTTP(rr, signedRequest(webhookBody, map[string]string{"X-Hub-Signature-256": "sha256=00"}))
+    if rr.Code != http.StatusUnauthorized {
+        t.Errorf("expected 401, got %d", rr.Code)
+    }
+}
+
+func TestHMACSigner_RoundTrip(t *testing.T) {
+    signer := &HMACSigner{Secret: []byte("shared")}
+    verifier := &WebhookVerifier{Secrets: [][]byte{[]byte("shared")}}
+
+    req := signedRequest(webhookBody, nil)
+    if err := signer.Authenticate(req, []byte(webhookBody)); err != nil {
+        t.Fatal(err)
+    }
+    if err := verifier.Verify(req.Header, []byte(webhookBody)); err != nil {
+        t.Errorf("signature made by HMACSigner not accepted: %v", err)
+    }
+
+    var testTools Tools
+    testTools.MaxJSONSize = 4
+    var data interface{}
+    err := testTools.ReadSignedJSON(httptest.NewRecorder(), signedRequest(webhookBody, nil), verifier, &data)
+    if err == nil || !strings.Contains(err.Error(), "large") {
+        t.Errorf("expected size error, got %v", err)
+    }
+}